@@ -0,0 +1,91 @@
+// Package errcode has the machine readable codes reported in OData error responses. Errors from the other packages
+// report their code with an ErrorCode method and, when they're about part of the input, its span with ErrorSpan.
+package errcode
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Code is a stable, machine readable error code.
+type Code string
+
+const (
+	// BadRequest is used for errors that don't have a more specific code.
+	BadRequest Code = "BadRequest"
+	// SyntaxError means the input couldn't be parsed.
+	SyntaxError Code = "SyntaxError"
+	// UnknownProperty means a property doesn't exist.
+	UnknownProperty Code = "UnknownProperty"
+	// UnsupportedFunction means a function or operator isn't supported, or isn't allowed.
+	UnsupportedFunction Code = "UnsupportedFunction"
+	// TypeMismatch means an operator or function was given a value of the wrong type.
+	TypeMismatch Code = "TypeMismatch"
+	// InvalidValue means a value is malformed or out of range, i.e. $top=-1.
+	InvalidValue Code = "InvalidValue"
+	// UnresolvedAlias means a parameter alias was used without a value.
+	UnresolvedAlias Code = "UnresolvedAlias"
+	// DuplicateOption means a query option was given more than once.
+	DuplicateOption Code = "DuplicateOption"
+	// UnsupportedMediaType means a request body isn't in a format that's understood.
+	UnsupportedMediaType Code = "UnsupportedMediaType"
+	// LimitExceeded means the request is bigger than the service allows.
+	LimitExceeded Code = "LimitExceeded"
+)
+
+// Span is the byte range of the input an error is about, End is exclusive.
+type Span struct {
+	Start int
+	End   int
+}
+
+type coder interface {
+	ErrorCode() Code
+}
+
+type spanner interface {
+	ErrorSpan() Span
+}
+
+// Of returns the code of the first error in the chain that has one, BadRequest if none do.
+func Of(err error) Code {
+	var c coder
+	if errors.As(err, &c) {
+		return c.ErrorCode()
+	}
+	return BadRequest
+}
+
+// SpanOf returns the span of the first error in the chain that has one.
+func SpanOf(err error) (Span, bool) {
+	var s spanner
+	if errors.As(err, &s) {
+		return s.ErrorSpan(), true
+	}
+	return Span{}, false
+}
+
+// Snippet marks the span of the input with carets on the line below it, for showing to developers.
+//
+//	Name eq ~Milk
+//	        ^
+func Snippet(input string, span Span) string {
+	start := clamp(span.Start, 0, len(input))
+	end := clamp(span.End, start, len(input))
+	width := utf8.RuneCountInString(input[start:end])
+	if width == 0 {
+		width = 1
+	}
+	return input + "\n" + strings.Repeat(" ", utf8.RuneCountInString(input[:start])) + strings.Repeat("^", width)
+}
+
+func clamp(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
@@ -0,0 +1,54 @@
+package errcode_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pboyd04/godata/errcode"
+	"github.com/pboyd04/godata/filter/lexer"
+)
+
+func TestOf(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		err      error
+		expected errcode.Code
+	}{
+		{errors.New("plain"), errcode.BadRequest},
+		{lexer.NoMatchingTokenError{Position: 1, End: 2}, errcode.SyntaxError},
+		{fmt.Errorf("wrapped: %w", lexer.NoMatchingTokenError{Position: 1, End: 2}), errcode.SyntaxError},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			t.Parallel()
+			if code := errcode.Of(tc.err); code != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, code)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input    string
+		span     errcode.Span
+		expected string
+	}{
+		{"Name eq 'Milk", errcode.Span{Start: 8, End: 13}, "Name eq 'Milk\n        ^^^^^"},
+		{"Name eq", errcode.Span{Start: 7, End: 7}, "Name eq\n       ^"},
+		{"Name eq 'Crème' or", errcode.Span{Start: 17, End: 19}, "Name eq 'Crème' or\n                ^^"},
+		{"Name", errcode.Span{Start: 2, End: 50}, "Name\n  ^^"},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			if snippet := errcode.Snippet(tc.input, tc.span); snippet != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, snippet)
+			}
+		})
+	}
+}
@@ -30,6 +30,12 @@ func (f *Filter) GetDBQueryWithReplacement(language string, a ...interface{}) (i
 	return f.myParser.GetDBQueryWithReplacement(language, a...)
 }
 
+// GetDBQueryWithParser is like GetDBQuery but uses the supplied parser rather than a registered one, i.e. a mongodb
+// parser with a different schema for each collection.
+func (f *Filter) GetDBQueryWithParser(dbParser parser.IParser) (interface{}, error) {
+	return dbParser.GetDBQuery(f.myParser)
+}
+
 func (f *Filter) GetCopyWithReplacements(a ...interface{}) (*Filter, error) {
 	myParser, err := f.myParser.ReplaceOperands(a...)
 	if err != nil {
@@ -37,3 +43,36 @@ func (f *Filter) GetCopyWithReplacements(a ...interface{}) (*Filter, error) {
 	}
 	return &Filter{myParser: myParser}, nil
 }
+
+// ResolveAliases returns a copy of the filter with the parameter aliases replaced by the supplied values.
+func (f *Filter) ResolveAliases(aliases map[string]string) (*Filter, error) {
+	myParser, err := f.myParser.ResolveAliases(aliases)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{myParser: myParser}, nil
+}
+
+// Bind returns a copy of the filter with the named placeholders replaced by the values. A placeholder is a string
+// literal holding a colon and a name, i.e. Tenant eq ':tenant'. A slice bound to the only element of an in list expands
+// into the list, so binding []string{"Milk", "Cheese"} to names turns Name in (':names') into
+// Name in ('Milk','Cheese'). Every placeholder must be bound.
+func (f *Filter) Bind(values map[string]interface{}) (*Filter, error) {
+	myParser, err := f.myParser.Bind(values)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{myParser: myParser}, nil
+}
+
+// Complexity measures the filter's nesting depth, in list sizes and functions. It only works on a filter straight from
+// NewFilter, before aliases are resolved or placeholders bound.
+func (f *Filter) Complexity() parser.Complexity {
+	return f.myParser.Complexity()
+}
+
+// TreeSize counts the nodes of the filter's expression tree and the values in its biggest in list. Unlike Complexity it
+// works after aliases are resolved, which is when it should be used.
+func (f *Filter) TreeSize() (parser.TreeSize, error) {
+	return f.myParser.TreeSize()
+}
@@ -1,9 +1,14 @@
 package filter_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/pboyd04/godata/filter"
+	"github.com/pboyd04/godata/filter/parser"
+	_ "github.com/pboyd04/godata/filter/parser/mysql"
+	"github.com/shopspring/decimal"
 )
 
 type filterTestData struct {
@@ -63,6 +68,12 @@ var testData = []filterTestData{
 	{
 		filterText: "contains(Name,'red')",
 	},
+	{
+		filterText: "not(Name eq'Milk')and trueCount gt 0 and order in('a')",
+	},
+	{
+		filterText: "contains(Şehir,'İ') and Straße eq 'Groß'",
+	},
 	{
 		filterText: `Address eq {"Street":"NE 40th","City":"Redmond","State":"WA","ZipCode":"98052"}`,
 	},
@@ -102,6 +113,9 @@ var testData = []filterTestData{
 	{
 		filterText: `Rating mod 5 eq 0`,
 	},
+	{
+		filterText: `case(Status eq 'A':1, Status eq 'B':2, true:0) gt 0`,
+	},
 }
 
 func TestFilterParsingGood(t *testing.T) {
@@ -141,3 +155,57 @@ func TestMustCompile(t *testing.T) {
 		// Didn't panic so all is good...
 	}
 }
+
+func TestBind(t *testing.T) {
+	t.Parallel()
+	values := map[string]interface{}{
+		"tenant": "acme",
+		"active": true,
+		"names":  []string{"Milk", "Cheese"},
+		"price":  decimal.RequireFromString("2.55"),
+		"none":   nil,
+	}
+	tests := map[string]string{
+		"Tenant eq ':tenant' and Name in (':names')": "`Tenant`='acme' AND `Name` IN ('Milk','Cheese')",
+		"Active eq ':active' or Price gt ':price'":   "`Active`=TRUE OR `Price`>2.55",
+		"Deleted eq ':none'":                         "`Deleted` IS NULL",
+	}
+	for input, expected := range tests {
+		f := filter.MustCompile(input)
+		bound, err := f.Bind(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := bound.GetDBQuery("mysql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != expected {
+			t.Errorf("expected %s, got %s", expected, res)
+		}
+		// The original filter is untouched
+		_, err = f.Bind(map[string]interface{}{})
+		if err == nil {
+			t.Errorf("%s should still have unbound placeholders", input)
+		}
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	t.Parallel()
+	f := filter.MustCompile("Tenant eq ':tenant' and Created lt ':created'")
+	_, err := f.Bind(map[string]interface{}{"tenant": "acme"})
+	var unbound *parser.UnboundPlaceholderError
+	if !errors.As(err, &unbound) || unbound.Name != "created" {
+		t.Errorf("expected unbound placeholder error for created, got %v", err)
+	}
+	_, err = f.Bind(map[string]interface{}{"tenant": struct{}{}, "created": time.Now()})
+	var invalid *parser.InvalidPlaceholderValueError
+	if !errors.As(err, &invalid) || invalid.Name != "tenant" {
+		t.Errorf("expected invalid value error for tenant, got %v", err)
+	}
+	_, err = f.Bind(map[string]interface{}{"tenant": []string{"acme"}, "created": time.Now()})
+	if !errors.Is(err, parser.ErrSliceOutsideIn) {
+		t.Errorf("expected slice error, got %v", err)
+	}
+}
@@ -2,10 +2,21 @@ package lexer
 
 import (
 	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pboyd04/godata/errcode"
 )
 
+// NoMatchingTokenError is returned when the input at Position isn't any kind of token.
 type NoMatchingTokenError struct {
 	Position int
+	// End is where the unmatched text stops, the next whitespace or the end of the input for an unterminated string
+	End int
+	// Text is the unmatched text
+	Text string
+	// Expected describes what should have been at Position
+	Expected string
 }
 
 type UnsupportedReplacementError struct {
@@ -13,17 +24,53 @@ type UnsupportedReplacementError struct {
 }
 
 func (e NoMatchingTokenError) Error() string {
-	return fmt.Sprintf("no matching token at position %d", e.Position)
+	if e.Expected == "" {
+		return fmt.Sprintf("no matching token at position %d", e.Position)
+	}
+	return fmt.Sprintf("no matching token %q at position %d, expected %s", e.Text, e.Position, e.Expected)
+}
+
+func (e NoMatchingTokenError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
 }
 
-func newNoMatchingTokenError(position int) error {
-	return NoMatchingTokenError{Position: position}
+func (e NoMatchingTokenError) ErrorSpan() errcode.Span {
+	return errcode.Span{Start: e.Position, End: e.End}
+}
+
+func newNoMatchingTokenError(text string, position int) error {
+	if position >= len(text) {
+		return NoMatchingTokenError{Position: position, End: position, Expected: "a token"}
+	}
+	if quote := text[position]; quote == '\'' || quote == '"' {
+		// Strings are the only tokens that can contain whitespace, so the rest of the input is the string
+		return NoMatchingTokenError{
+			Position: position,
+			End:      len(text),
+			Text:     text[position:],
+			Expected: fmt.Sprintf("%c to close the string started at %d", quote, position),
+		}
+	}
+	end := len(text)
+	if i := strings.IndexFunc(text[position:], unicode.IsSpace); i > 0 {
+		end = position + i
+	}
+	return NoMatchingTokenError{
+		Position: position,
+		End:      end,
+		Text:     text[position:end],
+		Expected: "a property, literal, operator or function",
+	}
 }
 
 func (e UnsupportedReplacementError) Error() string {
 	return e.message
 }
 
+func (e UnsupportedReplacementError) ErrorCode() errcode.Code {
+	return errcode.InvalidValue
+}
+
 func newUnsupportedReplacementError(format string, a ...interface{}) error {
 	return &UnsupportedReplacementError{message: fmt.Sprintf(format, a...)}
 }
@@ -5,7 +5,11 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
 )
 
 type TokenKey int
@@ -55,6 +59,7 @@ const (
 	Ceiling
 	Floor
 	Round
+	Case
 	Add
 	Subtract
 	Multiply
@@ -67,7 +72,15 @@ const (
 	FloatingPointLiteral
 	IntegerLiteral
 	Comma
-	// Not currently supported: date, maxDateTime, minDateTime, now, time, totalOffsetMinutes, totalSeconds, cast, isOf, geo.*, case, any, all.
+	ParameterAlias
+	// DateTimeOffsetLiteral is an unquoted timestamp such as 2020-01-01T10:30:00Z, or a placeholder replaced with a
+	// time.Time.
+	DateTimeOffsetLiteral
+	// DateLiteral is an unquoted date such as 2020-01-01.
+	DateLiteral
+	// GUIDLiteral is an unquoted GUID such as 01234567-89ab-cdef-0123-456789abcdef.
+	GUIDLiteral
+	// Not currently supported: date, maxDateTime, minDateTime, now, time, totalOffsetMinutes, totalSeconds, cast, isOf, geo.*, any, all.
 )
 
 type tokenMatcher func(string, *Lexer) int
@@ -98,13 +111,19 @@ func ptrFromConst(s string) *string {
 	return &s
 }
 
+// singleQuoteString matches an OData string, a quote inside the string is escaped by writing it twice.
 func singleQuoteString(s string, _ *Lexer) int {
 	if s[0] == '\'' {
 		length := len(s)
 		for i := 1; i < length; i++ {
-			if s[i] == '\'' {
-				return i + 1
+			if s[i] != '\'' {
+				continue
 			}
+			if i+1 < length && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
 		}
 	}
 	return -1
@@ -131,7 +150,7 @@ func testForFloat(s string, _ *Lexer) int {
 		startIndex = 1
 	}
 	length := len(s)
-	if startIndex >= length || !unicode.IsDigit(rune(s[startIndex])) {
+	if startIndex >= length || !isDigit(s[startIndex]) {
 		return -1
 	}
 	foundDot := false
@@ -143,7 +162,7 @@ func testForFloat(s string, _ *Lexer) int {
 			foundDot = true
 			continue
 		}
-		if !unicode.IsDigit(rune(s[i])) {
+		if !isDigit(s[i]) {
 			if !foundDot {
 				return -1
 			}
@@ -165,14 +184,14 @@ func testForInt(s string, _ *Lexer) int {
 		startIndex = 1
 	}
 	length := len(s)
-	if startIndex >= length || !unicode.IsDigit(rune(s[startIndex])) {
+	if startIndex >= length || !isDigit(s[startIndex]) {
 		return -1
 	}
 	for i := startIndex + 1; i < length; i++ {
-		if unicode.IsSpace(rune(s[i])) || s[i] == ',' || s[i] == ')' || s[i] == ']' || s[i] == '}' {
+		if isSpaceAt(s, i) || s[i] == ',' || s[i] == ')' || s[i] == ']' || s[i] == '}' || s[i] == ':' {
 			return i
 		}
-		if !unicode.IsDigit(rune(s[i-1])) {
+		if !isDigit(s[i]) {
 			// Found a letter right next to a digit this is something like a mongo id, treat it like a string
 			return -1
 		}
@@ -180,15 +199,206 @@ func testForInt(s string, _ *Lexer) int {
 	return length
 }
 
-// Replaces regexp.MustCompile(`([^\s,)'"]+)`) as this is faster.
+// testForUnquotedString matches a name, odataIdentifier segments joined by / for a property path or . for a qualified
+// name such as Sales.Color. A run of letters and digits starting with a digit, which is what a mongo id looks like once
+// testForInt has turned it down, is matched too. A doubled or trailing / stays in the name so the parser can report the
+// empty segment. Anything else, i.e. a backtick or a semicolon, isn't a token.
+//
+//nolint:cyclop // This is a simple function that is easy to understand
 func testForUnquotedString(s string, _ *Lexer) int {
 	length := len(s)
-	for i := 0; i < length; i++ {
-		if unicode.IsSpace(rune(s[i])) || s[i] == ',' || s[i] == ')' || s[i] == ']' || s[i] == '}' || s[i] == '\'' || s[i] == '"' {
+	if isDigit(s[0]) {
+		i := 1
+		for i < length && (isDigit(s[i]) || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z') {
+			i++
+		}
+		return i
+	}
+	i := 0
+	for {
+		start := i
+		for i < length {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if !isIdentifierChar(r) || i == start && !isIdentifierStart(r) {
+				break
+			}
+			i += size
+		}
+		if i == start {
+			if start > 0 && s[start-1] == '/' {
+				// An empty path segment, the parser reports it with the whole path
+				return start
+			}
+			// Nothing to start a name with, or a . that isn't followed by another segment
+			return start - 1
+		}
+		if i >= length || s[i] != '/' && s[i] != '.' {
 			return i
 		}
+		if s[i] == '.' {
+			i++
+			continue
+		}
+		for i < length && s[i] == '/' {
+			i++
+		}
 	}
-	return length
+}
+
+// testForDate matches a date such as 2020-01-01, which isn't the start of a dateTimeOffset or a longer name.
+func testForDate(s string, _ *Lexer) int {
+	if !isDate(s) || continuesLiteral(s, 10) {
+		return -1
+	}
+	return 10
+}
+
+// testForDateTimeOffset matches a timestamp such as 2020-01-01T10:30:00.5Z or 2020-01-01T10:30+02:00, the seconds and
+// fraction are optional but the offset isn't.
+//
+//nolint:cyclop // This is a simple function that is easy to understand
+func testForDateTimeOffset(s string, _ *Lexer) int {
+	if !isDate(s) || len(s) < 16 || s[10] != 'T' && s[10] != 't' || !digitsAt(s, 11, 2) || s[13] != ':' || !digitsAt(s, 14, 2) {
+		return -1
+	}
+	i := 16
+	if i < len(s) && s[i] == ':' {
+		if !digitsAt(s, i+1, 2) {
+			return -1
+		}
+		i += 3
+		if i < len(s) && s[i] == '.' {
+			i++
+			start := i
+			for i < len(s) && isDigit(s[i]) {
+				i++
+			}
+			if i == start {
+				return -1
+			}
+		}
+	}
+	switch {
+	case i < len(s) && (s[i] == 'Z' || s[i] == 'z'):
+		i++
+	case i < len(s) && (s[i] == '+' || s[i] == '-') && digitsAt(s, i+1, 2) && i+3 < len(s) && s[i+3] == ':' && digitsAt(s, i+4, 2):
+		i += 6
+	default:
+		return -1
+	}
+	if continuesLiteral(s, i) {
+		return -1
+	}
+	return i
+}
+
+// testForGUID matches a GUID, 32 hex digits in groups of 8-4-4-4-12.
+func testForGUID(s string, _ *Lexer) int {
+	i := 0
+	for group, size := range []int{8, 4, 4, 4, 12} {
+		if group > 0 {
+			if i >= len(s) || s[i] != '-' {
+				return -1
+			}
+			i++
+		}
+		for end := i + size; i < end; i++ {
+			if i >= len(s) || !isHexDigit(s[i]) {
+				return -1
+			}
+		}
+	}
+	if continuesLiteral(s, i) {
+		return -1
+	}
+	return i
+}
+
+// isDate is true if s starts with YYYY-MM-DD.
+func isDate(s string) bool {
+	return digitsAt(s, 0, 4) && len(s) > 4 && s[4] == '-' && digitsAt(s, 5, 2) && len(s) > 7 && s[7] == '-' && digitsAt(s, 8, 2)
+}
+
+// digitsAt is true if s has count digits starting at i.
+func digitsAt(s string, i, count int) bool {
+	if i+count > len(s) {
+		return false
+	}
+	for j := i; j < i+count; j++ {
+		if !isDigit(s[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// continuesLiteral is true if the literal ending at s[i] carries on, so it's actually something else.
+func continuesLiteral(s string, i int) bool {
+	return continuesWord(s, i) || i < len(s) && (s[i] == '-' || s[i] == ':')
+}
+
+// Matches a parameter alias such as @max.
+func testForParameterAlias(s string, _ *Lexer) int {
+	if s[0] != '@' {
+		return -1
+	}
+	length := len(s)
+	i := 1
+	for i < length {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isIdentifierChar(r) || i == 1 && !isIdentifierStart(r) {
+			break
+		}
+		i += size
+	}
+	if i == 1 {
+		return -1
+	}
+	return i
+}
+
+// isIdentifierStart is true for the characters an odataIdentifier can start with, an underscore or a letter
+// (categories L and Nl).
+func isIdentifierStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r)
+}
+
+// isIdentifierChar is true for the characters that can follow the first one of an odataIdentifier, which adds digits,
+// combining marks, connector punctuation and format characters (categories Nd, Mn, Mc, Pc and Cf).
+func isIdentifierChar(r rune) bool {
+	return isIdentifierStart(r) || unicode.In(r, unicode.Nd, unicode.Mn, unicode.Mc, unicode.Pc, unicode.Cf)
+}
+
+// isWord is true for a keyword such as eq or null, as opposed to punctuation, which has to be a whole word to match.
+func isWord(keyword string) bool {
+	return isIdentifierChar(rune(keyword[len(keyword)-1]))
+}
+
+// continuesWord is true if s[i] carries on the word before it, so the word is only the start of a name. A / or . joins
+// the word to the next segment of a path or qualified name, so Not/Value is a path, not the not operator.
+func continuesWord(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return isIdentifierChar(r) || r == '/' || r == '.'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isSpaceAt is true if the rune starting at s[i] is whitespace, which includes multibyte spaces such as U+3000.
+func isSpaceAt(s string, i int) bool {
+	if s[i] < utf8.RuneSelf {
+		return unicode.IsSpace(rune(s[i]))
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsSpace(r)
 }
 
 //nolint:gochecknoglobals // We only need to perform all this init once, otherwise we pay it every time we lex a string
@@ -204,17 +414,17 @@ var odataLexTypes = []TokenType{
 	{OpenCurlyBrace, nil, ptrFromConst("{"), nil},
 	{CloseCurlyBrace, nil, ptrFromConst("}"), nil},
 	{Colon, nil, ptrFromConst(":"), nil},
-	{Equals, nil, ptrFromConst("eq "), nil},
-	{NotEquals, nil, ptrFromConst("ne "), nil},
-	{GreaterThan, nil, ptrFromConst("gt "), nil},
-	{GreaterThanOrEqual, nil, ptrFromConst("ge "), nil},
-	{LessThan, nil, ptrFromConst("lt "), nil},
-	{LessThanOrEqual, nil, ptrFromConst("le "), nil},
-	{And, nil, ptrFromConst("and "), nil},
-	{Or, nil, ptrFromConst("or "), nil},
-	{Not, nil, ptrFromConst("not "), nil},
-	{Has, nil, ptrFromConst("has "), nil},
-	{In, nil, ptrFromConst("in "), nil},
+	{Equals, nil, ptrFromConst("eq"), nil},
+	{NotEquals, nil, ptrFromConst("ne"), nil},
+	{GreaterThan, nil, ptrFromConst("gt"), nil},
+	{GreaterThanOrEqual, nil, ptrFromConst("ge"), nil},
+	{LessThan, nil, ptrFromConst("lt"), nil},
+	{LessThanOrEqual, nil, ptrFromConst("le"), nil},
+	{And, nil, ptrFromConst("and"), nil},
+	{Or, nil, ptrFromConst("or"), nil},
+	{Not, nil, ptrFromConst("not"), nil},
+	{Has, nil, ptrFromConst("has"), nil},
+	{In, nil, ptrFromConst("in"), nil},
 	{Concat, nil, ptrFromConst("concat"), nil},
 	{Contains, nil, ptrFromConst("contains"), nil},
 	{EndsWith, nil, ptrFromConst("endswith"), nil},
@@ -238,39 +448,61 @@ var odataLexTypes = []TokenType{
 	{Ceiling, nil, ptrFromConst("ceiling"), nil},
 	{Floor, nil, ptrFromConst("floor"), nil},
 	{Round, nil, ptrFromConst("round"), nil},
-	{Add, nil, ptrFromConst("add "), nil},
-	{Subtract, nil, ptrFromConst("sub "), nil},
-	{Multiply, nil, ptrFromConst("mul "), nil},
-	{Divide, nil, ptrFromConst("div "), nil},
-	{DivideFloat, nil, ptrFromConst("divby "), nil},
-	{Modulo, nil, ptrFromConst("mod "), nil},
+	{Case, nil, ptrFromConst("case"), nil},
+	{Add, nil, ptrFromConst("add"), nil},
+	{Subtract, nil, ptrFromConst("sub"), nil},
+	{Multiply, nil, ptrFromConst("mul"), nil},
+	{Divide, nil, ptrFromConst("div"), nil},
+	{DivideFloat, nil, ptrFromConst("divby"), nil},
+	{Modulo, nil, ptrFromConst("mod"), nil},
 	{NullLiteral, nil, ptrFromConst("null"), nil},
 	{Comma, nil, ptrFromConst(","), nil},
+	{ParameterAlias, nil, nil, testForParameterAlias},
+	// Ahead of the numbers, which would otherwise take the year
+	{DateTimeOffsetLiteral, nil, nil, testForDateTimeOffset},
+	{DateLiteral, nil, nil, testForDate},
+	{GUIDLiteral, nil, nil, testForGUID},
 	{FloatingPointLiteral, nil, nil, testForFloat},
 	{IntegerLiteral, nil, nil, testForInt},
 	// Needs to be near the end otherwise it will match everything
 	{UnquotedString, nil, nil, testForUnquotedString},
 }
 
-//nolint:funlen
 func NewLexer(input string) *Lexer {
 	ret := &Lexer{text: input, position: 0}
 	// Avoid the need for case-insensitive regex/string compare
-	ret.lower = strings.ToLower(input)
+	ret.lower = foldCase(input)
 	ret.types = odataLexTypes
 	ret.length = len(input)
-	if ret.length != len(ret.lower) {
-		ret.length = -1
-	}
 	return ret
 }
 
+// foldCase lower cases s without moving any byte offsets, so positions in the folded text are positions in s. A rune
+// whose lower case is encoded in a different number of bytes, i.e. İ, is kept as it is. That never matters for
+// matching as every keyword is ASCII and no other rune folds into ASCII with the same length.
+func foldCase(s string) string {
+	var ret strings.Builder
+	ret.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		lower := unicode.ToLower(r)
+		if r == utf8.RuneError || utf8.RuneLen(lower) != size {
+			// Invalid UTF-8 is copied byte for byte as writing RuneError would make it longer
+			ret.WriteString(s[i : i+size])
+		} else {
+			ret.WriteRune(lower)
+		}
+		i += size
+	}
+	return ret.String()
+}
+
 func (l *Lexer) testMatchingFunction(t TokenType) (*Token, error) {
 	length := t.matcherFn(l.lower[l.position:], l)
 	if length > 0 {
 		start := l.position
 		if start+length > l.length {
-			return nil, newNoMatchingTokenError(start)
+			return nil, newNoMatchingTokenError(l.text, start)
 		}
 		l.position += length
 		return &Token{Type: t.typeKey, Start: start, End: l.position, Text: l.text[start:l.position]}, nil
@@ -293,7 +525,11 @@ func (l *Lexer) testStringMatch(t TokenType) (*Token, error) {
 		start := l.position
 		length := len(*t.stringMatch)
 		if start+length > l.length {
-			return nil, newNoMatchingTokenError(start)
+			return nil, newNoMatchingTokenError(l.text, start)
+		}
+		if isWord(*t.stringMatch) && continuesWord(l.text, start+length) {
+			// A keyword at the start of a name, i.e. the or of order or the true of trueCount
+			return nil, nil
 		}
 		l.position += length
 		return &Token{Type: t.typeKey, Start: start, End: l.position, Text: l.text[start:l.position]}, nil
@@ -344,8 +580,9 @@ func (l *Lexer) NextToken() (*Token, error) {
 		return nil, nil
 	}
 	// Skip whitespace
-	if unicode.IsSpace(rune(l.text[l.position])) {
-		l.position++
+	for isSpaceAt(l.text, l.position) {
+		_, size := utf8.DecodeRuneInString(l.text[l.position:])
+		l.position += size
 		if l.position >= l.length {
 			return nil, nil
 		}
@@ -359,7 +596,7 @@ func (l *Lexer) NextToken() (*Token, error) {
 			return res, nil
 		}
 	}
-	return nil, newNoMatchingTokenError(l.position)
+	return nil, newNoMatchingTokenError(l.text, l.position)
 }
 
 func (t *Token) IsUnary() bool {
@@ -386,7 +623,7 @@ func (t TokenKey) HasParameters() bool {
 	switch t {
 	case Concat, Contains, EndsWith, IndexOf, Length, StartsWith, Substring, HasSubset, HasSubsequence,
 		MatchesPattern, ToLower, ToUpper, Trim, Day, FractionalSeconds, Hour, Minute, Month, Second,
-		Year, Ceiling, Floor, Round:
+		Year, Ceiling, Floor, Round, Case:
 		return true
 	default:
 		return false
@@ -404,7 +641,9 @@ func (t Token) GetData() (interface{}, error) {
 		return true, nil
 	case TokenFalse:
 		return false, nil
-	case SingleQuotedString, DoubleQuotedString:
+	case SingleQuotedString:
+		return UnescapeString(str), nil
+	case DoubleQuotedString:
 		// Remove the quotes
 		return str[1 : len(str)-1], nil
 	case NullLiteral:
@@ -413,34 +652,71 @@ func (t Token) GetData() (interface{}, error) {
 		return strconv.ParseFloat(str, 64)
 	case IntegerLiteral:
 		return strconv.Atoi(str)
+	case DateTimeOffsetLiteral:
+		ret, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			// The seconds are optional
+			return time.Parse("2006-01-02T15:04Z07:00", str)
+		}
+		return ret, nil
 	default:
 		return str, nil
 	}
 }
 
 func (t *Token) IsCorrectReplacement(index int) bool {
-	var strCmp string
-	switch t.Type {
-	case SingleQuotedString:
-		strCmp = "':" + strconv.Itoa(index) + "'"
-	case DoubleQuotedString:
-		strCmp = `":` + strconv.Itoa(index) + `"`
-	default:
-		return false
+	name, ok := t.PlaceholderName()
+	return ok && name == strconv.Itoa(index)
+}
+
+// PlaceholderName returns the name of a placeholder such as ':tenant' or ':0', the second return value is false if the
+// token isn't a placeholder.
+func (t *Token) PlaceholderName() (string, bool) {
+	if t.Type != SingleQuotedString && t.Type != DoubleQuotedString {
+		return "", false
+	}
+	name := t.Text[1 : len(t.Text)-1]
+	if len(name) < 2 || name[0] != ':' {
+		return "", false
 	}
-	return t.Text == strCmp
+	for i, r := range name[1:] {
+		if !isIdentifierChar(r) || i == 0 && !isIdentifierStart(r) && !unicode.IsDigit(r) {
+			return "", false
+		}
+	}
+	return name[1:], true
 }
 
+//nolint:cyclop // This is just a big type switch
 func (t *Token) Replace(operand interface{}) error {
 	switch operand := operand.(type) {
 	case string:
-		t.Text = "'" + operand + "'"
+		t.Text = QuoteString(operand)
+		t.Type = SingleQuotedString
 	case int:
 		t.Text = strconv.Itoa(operand)
 		t.Type = IntegerLiteral
+	case int64:
+		t.Text = strconv.FormatInt(operand, 10)
+		t.Type = IntegerLiteral
 	case float64:
 		t.Text = strconv.FormatFloat(operand, 'f', -1, 64)
 		t.Type = FloatingPointLiteral
+	case decimal.Decimal:
+		t.Text = operand.String()
+		t.Type = FloatingPointLiteral
+	case bool:
+		t.Text = strconv.FormatBool(operand)
+		t.Type = TokenFalse
+		if operand {
+			t.Type = TokenTrue
+		}
+	case nil:
+		t.Text = "null"
+		t.Type = NullLiteral
+	case time.Time:
+		t.Text = operand.Format(time.RFC3339Nano)
+		t.Type = DateTimeOffsetLiteral
 	default:
 		return newUnsupportedReplacementError("unsupported type %T", operand)
 	}
@@ -542,6 +818,8 @@ func (t TokenKey) String() string {
 		return "Floor"
 	case Round:
 		return "Round"
+	case Case:
+		return "Case"
 	case Add:
 		return "Add"
 	case Subtract:
@@ -566,7 +844,29 @@ func (t TokenKey) String() string {
 		return "IntegerLiteral"
 	case Comma:
 		return "Comma"
+	case ParameterAlias:
+		return "ParameterAlias"
+	case DateTimeOffsetLiteral:
+		return "DateTimeOffsetLiteral"
+	case DateLiteral:
+		return "DateLiteral"
+	case GUIDLiteral:
+		return "GUIDLiteral"
 	default:
 		return strconv.Itoa(int(t))
 	}
 }
+
+// QuoteString writes s as an OData string literal, quotes are doubled so that UnescapeString gives s back.
+func QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// UnescapeString returns the value of an OData string literal with the outer quotes removed and doubled quotes made
+// one. Percent-encoding is undone when the query string is parsed, so a %5E here is the text %5E.
+func UnescapeString(text string) string {
+	if len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'' {
+		text = text[1 : len(text)-1]
+	}
+	return strings.ReplaceAll(text, "''", "'")
+}
@@ -1,6 +1,7 @@
 package lexer_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/pboyd04/godata/filter/lexer"
@@ -29,7 +30,7 @@ var tests = []testData{
 		input: "Name eq 'Milk'",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.Equals, Start: 5, End: 8},
+			{Type: lexer.Equals, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
 		},
 	},
@@ -38,7 +39,7 @@ var tests = []testData{
 		expected: []lexer.Token{
 			{Type: lexer.OpenParens, Start: 0, End: 1},
 			{Type: lexer.UnquotedString, Start: 1, End: 5},
-			{Type: lexer.Equals, Start: 6, End: 9},
+			{Type: lexer.Equals, Start: 6, End: 8},
 			{Type: lexer.SingleQuotedString, Start: 9, End: 15},
 			{Type: lexer.CloseParens, Start: 15, End: 16},
 		},
@@ -47,7 +48,7 @@ var tests = []testData{
 		input: "Name ne 'Milk'",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.NotEquals, Start: 5, End: 8},
+			{Type: lexer.NotEquals, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
 		},
 	},
@@ -55,7 +56,7 @@ var tests = []testData{
 		input: "Name gt 'Milk'",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.GreaterThan, Start: 5, End: 8},
+			{Type: lexer.GreaterThan, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
 		},
 	},
@@ -63,7 +64,7 @@ var tests = []testData{
 		input: "Name ge 'Milk'",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.GreaterThanOrEqual, Start: 5, End: 8},
+			{Type: lexer.GreaterThanOrEqual, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
 		},
 	},
@@ -71,7 +72,7 @@ var tests = []testData{
 		input: "Name lt 'Milk'",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.LessThan, Start: 5, End: 8},
+			{Type: lexer.LessThan, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
 		},
 	},
@@ -79,7 +80,7 @@ var tests = []testData{
 		input: "Name le 'Milk'",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.LessThanOrEqual, Start: 5, End: 8},
+			{Type: lexer.LessThanOrEqual, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
 		},
 	},
@@ -87,11 +88,11 @@ var tests = []testData{
 		input: "Name eq 'Milk' and Price lt 2.55",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.Equals, Start: 5, End: 8},
+			{Type: lexer.Equals, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
-			{Type: lexer.And, Start: 15, End: 19},
+			{Type: lexer.And, Start: 15, End: 18},
 			{Type: lexer.UnquotedString, Start: 19, End: 24},
-			{Type: lexer.LessThan, Start: 25, End: 28},
+			{Type: lexer.LessThan, Start: 25, End: 27},
 			{Type: lexer.FloatingPointLiteral, Start: 28, End: 32},
 		},
 	},
@@ -99,11 +100,11 @@ var tests = []testData{
 		input: "Name EQ 'Milk' AND Price LT 2.55",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.Equals, Start: 5, End: 8},
+			{Type: lexer.Equals, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
-			{Type: lexer.And, Start: 15, End: 19},
+			{Type: lexer.And, Start: 15, End: 18},
 			{Type: lexer.UnquotedString, Start: 19, End: 24},
-			{Type: lexer.LessThan, Start: 25, End: 28},
+			{Type: lexer.LessThan, Start: 25, End: 27},
 			{Type: lexer.FloatingPointLiteral, Start: 28, End: 32},
 		},
 	},
@@ -111,11 +112,11 @@ var tests = []testData{
 		input: "Name eq 'Milk' AND Price LT 2.55",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.Equals, Start: 5, End: 8},
+			{Type: lexer.Equals, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
-			{Type: lexer.And, Start: 15, End: 19},
+			{Type: lexer.And, Start: 15, End: 18},
 			{Type: lexer.UnquotedString, Start: 19, End: 24},
-			{Type: lexer.LessThan, Start: 25, End: 28},
+			{Type: lexer.LessThan, Start: 25, End: 27},
 			{Type: lexer.FloatingPointLiteral, Start: 28, End: 32},
 		},
 	},
@@ -123,11 +124,11 @@ var tests = []testData{
 		input: "Name eq 'Milk' or Price lt 2.55",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.Equals, Start: 5, End: 8},
+			{Type: lexer.Equals, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 14},
-			{Type: lexer.Or, Start: 15, End: 18},
+			{Type: lexer.Or, Start: 15, End: 17},
 			{Type: lexer.UnquotedString, Start: 18, End: 23},
-			{Type: lexer.LessThan, Start: 24, End: 27},
+			{Type: lexer.LessThan, Start: 24, End: 26},
 			{Type: lexer.FloatingPointLiteral, Start: 27, End: 31},
 		},
 	},
@@ -135,7 +136,7 @@ var tests = []testData{
 		input: "Name in ('Milk', 'Cheese')",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.In, Start: 5, End: 8},
+			{Type: lexer.In, Start: 5, End: 7},
 			{Type: lexer.OpenParens, Start: 8, End: 9},
 			{Type: lexer.SingleQuotedString, Start: 9, End: 15},
 			{Type: lexer.Comma, Start: 15, End: 16},
@@ -147,7 +148,7 @@ var tests = []testData{
 		input: "Name in ['Milk', 'Cheese']",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.In, Start: 5, End: 8},
+			{Type: lexer.In, Start: 5, End: 7},
 			{Type: lexer.OpenSquareBracket, Start: 8, End: 9},
 			{Type: lexer.SingleQuotedString, Start: 9, End: 15},
 			{Type: lexer.Comma, Start: 15, End: 16},
@@ -159,10 +160,34 @@ var tests = []testData{
 		input: "_id eq 6206b158000e1859781d5e16",
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 3},
-			{Type: lexer.Equals, Start: 4, End: 7},
+			{Type: lexer.Equals, Start: 4, End: 6},
 			{Type: lexer.UnquotedString, Start: 7, End: 31},
 		},
 	},
+	{
+		input: "BirthDate gt 2020-01-01 and Created lt 2020-01-01T10:30:00.5+02:00 and Id eq 01234567-89ab-cdef-0123-456789ABCDEF",
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 9},
+			{Type: lexer.GreaterThan, Start: 10, End: 12},
+			{Type: lexer.DateLiteral, Start: 13, End: 23},
+			{Type: lexer.And, Start: 24, End: 27},
+			{Type: lexer.UnquotedString, Start: 28, End: 35},
+			{Type: lexer.LessThan, Start: 36, End: 38},
+			{Type: lexer.DateTimeOffsetLiteral, Start: 39, End: 66},
+			{Type: lexer.And, Start: 67, End: 70},
+			{Type: lexer.UnquotedString, Start: 71, End: 73},
+			{Type: lexer.Equals, Start: 74, End: 76},
+			{Type: lexer.GUIDLiteral, Start: 77, End: 113},
+		},
+	},
+	{
+		input: "Created ge 2020-01-01T10:30Z",
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 7},
+			{Type: lexer.GreaterThanOrEqual, Start: 8, End: 10},
+			{Type: lexer.DateTimeOffsetLiteral, Start: 11, End: 28},
+		},
+	},
 	{
 		input: "contains(Name,'red')",
 		expected: []lexer.Token{
@@ -179,7 +204,7 @@ var tests = []testData{
 		input: `Address eq {"Street":"NE 40th","City":"Redmond","State":"WA","ZipCode":"98052"}`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 7},
-			{Type: lexer.Equals, Start: 8, End: 11},
+			{Type: lexer.Equals, Start: 8, End: 10},
 			{Type: lexer.OpenCurlyBrace, Start: 11, End: 12},
 			{Type: lexer.DoubleQuotedString, Start: 12, End: 20},
 			{Type: lexer.Colon, Start: 20, End: 21},
@@ -213,7 +238,7 @@ var tests = []testData{
 	{
 		input: "not endswith(Name,'ilk')",
 		expected: []lexer.Token{
-			{Type: lexer.Not, Start: 0, End: 4},
+			{Type: lexer.Not, Start: 0, End: 3},
 			{Type: lexer.EndsWith, Start: 4, End: 12},
 			{Type: lexer.OpenParens, Start: 12, End: 13},
 			{Type: lexer.UnquotedString, Start: 13, End: 17},
@@ -229,7 +254,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 6, End: 7},
 			{Type: lexer.UnquotedString, Start: 7, End: 18},
 			{Type: lexer.CloseParens, Start: 18, End: 19},
-			{Type: lexer.Equals, Start: 20, End: 23},
+			{Type: lexer.Equals, Start: 20, End: 22},
 			{Type: lexer.IntegerLiteral, Start: 23, End: 25},
 		},
 	},
@@ -263,9 +288,9 @@ var tests = []testData{
 		input: `Price add 2.45 eq 5.00`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 5},
-			{Type: lexer.Add, Start: 6, End: 10},
+			{Type: lexer.Add, Start: 6, End: 9},
 			{Type: lexer.FloatingPointLiteral, Start: 10, End: 14},
-			{Type: lexer.Equals, Start: 15, End: 18},
+			{Type: lexer.Equals, Start: 15, End: 17},
 			{Type: lexer.FloatingPointLiteral, Start: 18, End: 22},
 		},
 	},
@@ -273,9 +298,9 @@ var tests = []testData{
 		input: `Price sub 0.55 eq 2.00`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 5},
-			{Type: lexer.Subtract, Start: 6, End: 10},
+			{Type: lexer.Subtract, Start: 6, End: 9},
 			{Type: lexer.FloatingPointLiteral, Start: 10, End: 14},
-			{Type: lexer.Equals, Start: 15, End: 18},
+			{Type: lexer.Equals, Start: 15, End: 17},
 			{Type: lexer.FloatingPointLiteral, Start: 18, End: 22},
 		},
 	},
@@ -283,9 +308,9 @@ var tests = []testData{
 		input: `Price mul 2.0 eq 5.10`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 5},
-			{Type: lexer.Multiply, Start: 6, End: 10},
+			{Type: lexer.Multiply, Start: 6, End: 9},
 			{Type: lexer.FloatingPointLiteral, Start: 10, End: 13},
-			{Type: lexer.Equals, Start: 14, End: 17},
+			{Type: lexer.Equals, Start: 14, End: 16},
 			{Type: lexer.FloatingPointLiteral, Start: 17, End: 21},
 		},
 	},
@@ -293,9 +318,9 @@ var tests = []testData{
 		input: `Price div 2.55 eq 1`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 5},
-			{Type: lexer.Divide, Start: 6, End: 10},
+			{Type: lexer.Divide, Start: 6, End: 9},
 			{Type: lexer.FloatingPointLiteral, Start: 10, End: 14},
-			{Type: lexer.Equals, Start: 15, End: 18},
+			{Type: lexer.Equals, Start: 15, End: 17},
 			{Type: lexer.IntegerLiteral, Start: 18, End: 19},
 		},
 	},
@@ -303,9 +328,9 @@ var tests = []testData{
 		input: `Price div 2 eq 2`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 5},
-			{Type: lexer.Divide, Start: 6, End: 10},
+			{Type: lexer.Divide, Start: 6, End: 9},
 			{Type: lexer.IntegerLiteral, Start: 10, End: 11},
-			{Type: lexer.Equals, Start: 12, End: 15},
+			{Type: lexer.Equals, Start: 12, End: 14},
 			{Type: lexer.IntegerLiteral, Start: 15, End: 16},
 		},
 	},
@@ -313,9 +338,9 @@ var tests = []testData{
 		input: `Price divby 2 eq 2.5`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 5},
-			{Type: lexer.DivideFloat, Start: 6, End: 12},
+			{Type: lexer.DivideFloat, Start: 6, End: 11},
 			{Type: lexer.IntegerLiteral, Start: 12, End: 13},
-			{Type: lexer.Equals, Start: 14, End: 17},
+			{Type: lexer.Equals, Start: 14, End: 16},
 			{Type: lexer.FloatingPointLiteral, Start: 17, End: 20},
 		},
 	},
@@ -323,9 +348,9 @@ var tests = []testData{
 		input: `Rating mod 5 eq 0`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 6},
-			{Type: lexer.Modulo, Start: 7, End: 11},
+			{Type: lexer.Modulo, Start: 7, End: 10},
 			{Type: lexer.IntegerLiteral, Start: 11, End: 12},
-			{Type: lexer.Equals, Start: 13, End: 16},
+			{Type: lexer.Equals, Start: 13, End: 15},
 			{Type: lexer.IntegerLiteral, Start: 16, End: 17},
 		},
 	},
@@ -333,7 +358,7 @@ var tests = []testData{
 		input: `style has Sales.Pattern'Yellow'`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 5},
-			{Type: lexer.Has, Start: 6, End: 10},
+			{Type: lexer.Has, Start: 6, End: 9},
 			{Type: lexer.UnquotedString, Start: 10, End: 23},
 			{Type: lexer.SingleQuotedString, Start: 23, End: 31},
 		},
@@ -343,16 +368,16 @@ var tests = []testData{
 		expected: []lexer.Token{
 			{Type: lexer.OpenParens, Start: 0, End: 1},
 			{Type: lexer.IntegerLiteral, Start: 1, End: 2},
-			{Type: lexer.Add, Start: 3, End: 7},
+			{Type: lexer.Add, Start: 3, End: 6},
 			{Type: lexer.IntegerLiteral, Start: 7, End: 8},
 			{Type: lexer.CloseParens, Start: 8, End: 9},
-			{Type: lexer.Modulo, Start: 10, End: 14},
+			{Type: lexer.Modulo, Start: 10, End: 13},
 			{Type: lexer.OpenParens, Start: 14, End: 15},
 			{Type: lexer.IntegerLiteral, Start: 15, End: 16},
-			{Type: lexer.Subtract, Start: 17, End: 21},
+			{Type: lexer.Subtract, Start: 17, End: 20},
 			{Type: lexer.IntegerLiteral, Start: 21, End: 22},
 			{Type: lexer.CloseParens, Start: 22, End: 23},
-			{Type: lexer.Equals, Start: 24, End: 27},
+			{Type: lexer.Equals, Start: 24, End: 26},
 			{Type: lexer.IntegerLiteral, Start: 27, End: 28},
 		},
 	},
@@ -370,7 +395,7 @@ var tests = []testData{
 			{Type: lexer.Comma, Start: 24, End: 25},
 			{Type: lexer.UnquotedString, Start: 25, End: 32},
 			{Type: lexer.CloseParens, Start: 32, End: 33},
-			{Type: lexer.Equals, Start: 34, End: 37},
+			{Type: lexer.Equals, Start: 34, End: 36},
 			{Type: lexer.SingleQuotedString, Start: 37, End: 54},
 		},
 	},
@@ -383,7 +408,7 @@ var tests = []testData{
 			{Type: lexer.Comma, Start: 19, End: 20},
 			{Type: lexer.SingleQuotedString, Start: 20, End: 28},
 			{Type: lexer.CloseParens, Start: 28, End: 29},
-			{Type: lexer.Equals, Start: 30, End: 33},
+			{Type: lexer.Equals, Start: 30, End: 32},
 			{Type: lexer.IntegerLiteral, Start: 33, End: 34},
 		},
 	},
@@ -396,7 +421,7 @@ var tests = []testData{
 			{Type: lexer.Comma, Start: 21, End: 22},
 			{Type: lexer.IntegerLiteral, Start: 22, End: 23},
 			{Type: lexer.CloseParens, Start: 23, End: 24},
-			{Type: lexer.Equals, Start: 25, End: 28},
+			{Type: lexer.Equals, Start: 25, End: 27},
 			{Type: lexer.SingleQuotedString, Start: 28, End: 48},
 		},
 	},
@@ -411,7 +436,7 @@ var tests = []testData{
 			{Type: lexer.Comma, Start: 23, End: 24},
 			{Type: lexer.IntegerLiteral, Start: 24, End: 25},
 			{Type: lexer.CloseParens, Start: 25, End: 26},
-			{Type: lexer.Equals, Start: 27, End: 30},
+			{Type: lexer.Equals, Start: 27, End: 29},
 			{Type: lexer.SingleQuotedString, Start: 30, End: 34},
 		},
 	},
@@ -586,7 +611,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 7, End: 8},
 			{Type: lexer.UnquotedString, Start: 8, End: 19},
 			{Type: lexer.CloseParens, Start: 19, End: 20},
-			{Type: lexer.Equals, Start: 21, End: 24},
+			{Type: lexer.Equals, Start: 21, End: 23},
 			{Type: lexer.SingleQuotedString, Start: 24, End: 45},
 		},
 	},
@@ -597,7 +622,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 7, End: 8},
 			{Type: lexer.UnquotedString, Start: 8, End: 19},
 			{Type: lexer.CloseParens, Start: 19, End: 20},
-			{Type: lexer.Equals, Start: 21, End: 24},
+			{Type: lexer.Equals, Start: 21, End: 23},
 			{Type: lexer.SingleQuotedString, Start: 24, End: 45},
 		},
 	},
@@ -608,7 +633,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 4, End: 5},
 			{Type: lexer.UnquotedString, Start: 5, End: 16},
 			{Type: lexer.CloseParens, Start: 16, End: 17},
-			{Type: lexer.Equals, Start: 18, End: 21},
+			{Type: lexer.Equals, Start: 18, End: 20},
 			{Type: lexer.UnquotedString, Start: 21, End: 32},
 		},
 	},
@@ -619,7 +644,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 3, End: 4},
 			{Type: lexer.UnquotedString, Start: 4, End: 13},
 			{Type: lexer.CloseParens, Start: 13, End: 14},
-			{Type: lexer.Equals, Start: 15, End: 18},
+			{Type: lexer.Equals, Start: 15, End: 17},
 			{Type: lexer.IntegerLiteral, Start: 18, End: 19},
 		},
 	},
@@ -630,7 +655,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 17, End: 18},
 			{Type: lexer.UnquotedString, Start: 18, End: 27},
 			{Type: lexer.CloseParens, Start: 27, End: 28},
-			{Type: lexer.LessThan, Start: 29, End: 32},
+			{Type: lexer.LessThan, Start: 29, End: 31},
 			{Type: lexer.FloatingPointLiteral, Start: 32, End: 35},
 		},
 	},
@@ -641,7 +666,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 4, End: 5},
 			{Type: lexer.UnquotedString, Start: 5, End: 14},
 			{Type: lexer.CloseParens, Start: 14, End: 15},
-			{Type: lexer.Equals, Start: 16, End: 19},
+			{Type: lexer.Equals, Start: 16, End: 18},
 			{Type: lexer.IntegerLiteral, Start: 19, End: 20},
 		},
 	},
@@ -652,7 +677,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 6, End: 7},
 			{Type: lexer.UnquotedString, Start: 7, End: 16},
 			{Type: lexer.CloseParens, Start: 16, End: 17},
-			{Type: lexer.Equals, Start: 18, End: 21},
+			{Type: lexer.Equals, Start: 18, End: 20},
 			{Type: lexer.IntegerLiteral, Start: 21, End: 23},
 		},
 	},
@@ -663,7 +688,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 5, End: 6},
 			{Type: lexer.UnquotedString, Start: 6, End: 15},
 			{Type: lexer.CloseParens, Start: 15, End: 16},
-			{Type: lexer.Equals, Start: 17, End: 20},
+			{Type: lexer.Equals, Start: 17, End: 19},
 			{Type: lexer.IntegerLiteral, Start: 20, End: 21},
 		},
 	},
@@ -674,7 +699,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 6, End: 7},
 			{Type: lexer.UnquotedString, Start: 7, End: 16},
 			{Type: lexer.CloseParens, Start: 16, End: 17},
-			{Type: lexer.Equals, Start: 18, End: 21},
+			{Type: lexer.Equals, Start: 18, End: 20},
 			{Type: lexer.IntegerLiteral, Start: 21, End: 23},
 		},
 	},
@@ -685,7 +710,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 4, End: 5},
 			{Type: lexer.UnquotedString, Start: 5, End: 14},
 			{Type: lexer.CloseParens, Start: 14, End: 15},
-			{Type: lexer.Equals, Start: 16, End: 19},
+			{Type: lexer.Equals, Start: 16, End: 18},
 			{Type: lexer.IntegerLiteral, Start: 19, End: 23},
 		},
 	},
@@ -696,7 +721,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 7, End: 8},
 			{Type: lexer.UnquotedString, Start: 8, End: 15},
 			{Type: lexer.CloseParens, Start: 15, End: 16},
-			{Type: lexer.Equals, Start: 17, End: 20},
+			{Type: lexer.Equals, Start: 17, End: 19},
 			{Type: lexer.IntegerLiteral, Start: 20, End: 22},
 		},
 	},
@@ -707,7 +732,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 5, End: 6},
 			{Type: lexer.UnquotedString, Start: 6, End: 13},
 			{Type: lexer.CloseParens, Start: 13, End: 14},
-			{Type: lexer.Equals, Start: 15, End: 18},
+			{Type: lexer.Equals, Start: 15, End: 17},
 			{Type: lexer.IntegerLiteral, Start: 18, End: 20},
 		},
 	},
@@ -718,7 +743,7 @@ var tests = []testData{
 			{Type: lexer.OpenParens, Start: 5, End: 6},
 			{Type: lexer.UnquotedString, Start: 6, End: 13},
 			{Type: lexer.CloseParens, Start: 13, End: 14},
-			{Type: lexer.Equals, Start: 15, End: 18},
+			{Type: lexer.Equals, Start: 15, End: 17},
 			{Type: lexer.IntegerLiteral, Start: 18, End: 20},
 		},
 	},
@@ -726,7 +751,7 @@ var tests = []testData{
 		input: `DiscontinuedDate eq null`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 16},
-			{Type: lexer.Equals, Start: 17, End: 20},
+			{Type: lexer.Equals, Start: 17, End: 19},
 			{Type: lexer.NullLiteral, Start: 20, End: 24},
 		},
 	},
@@ -734,19 +759,127 @@ var tests = []testData{
 		input: `style has Sales.Pattern'Yellow'`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 5},
-			{Type: lexer.Has, Start: 6, End: 10},
+			{Type: lexer.Has, Start: 6, End: 9},
 			{Type: lexer.UnquotedString, Start: 10, End: 23},
 			{Type: lexer.SingleQuotedString, Start: 23, End: 31},
 		},
 	},
+	{
+		input: `case(Status eq 'A':1,true:0) gt 0`,
+		expected: []lexer.Token{
+			{Type: lexer.Case, Start: 0, End: 4},
+			{Type: lexer.OpenParens, Start: 4, End: 5},
+			{Type: lexer.UnquotedString, Start: 5, End: 11},
+			{Type: lexer.Equals, Start: 12, End: 14},
+			{Type: lexer.SingleQuotedString, Start: 15, End: 18},
+			{Type: lexer.Colon, Start: 18, End: 19},
+			{Type: lexer.IntegerLiteral, Start: 19, End: 20},
+			{Type: lexer.Comma, Start: 20, End: 21},
+			{Type: lexer.TokenTrue, Start: 21, End: 25},
+			{Type: lexer.Colon, Start: 25, End: 26},
+			{Type: lexer.IntegerLiteral, Start: 26, End: 27},
+			{Type: lexer.CloseParens, Start: 27, End: 28},
+			{Type: lexer.GreaterThan, Start: 29, End: 31},
+			{Type: lexer.IntegerLiteral, Start: 32, End: 33},
+		},
+	},
+	{
+		input: `Price lt @max`,
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 5},
+			{Type: lexer.LessThan, Start: 6, End: 8},
+			{Type: lexer.ParameterAlias, Start: 9, End: 13},
+		},
+	},
 	{
 		input: `year eq ':0'`,
 		expected: []lexer.Token{
 			{Type: lexer.UnquotedString, Start: 0, End: 4},
-			{Type: lexer.Equals, Start: 5, End: 8},
+			{Type: lexer.Equals, Start: 5, End: 7},
 			{Type: lexer.SingleQuotedString, Start: 8, End: 12},
 		},
 	},
+	{
+		input: `Name eq 'O''Neil'`,
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 4},
+			{Type: lexer.Equals, Start: 5, End: 7},
+			{Type: lexer.SingleQuotedString, Start: 8, End: 17},
+		},
+	},
+	{
+		input: `Name eq 'İstanbul'`,
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 4},
+			{Type: lexer.Equals, Start: 5, End: 7},
+			{Type: lexer.SingleQuotedString, Start: 8, End: 19},
+		},
+	},
+	{
+		input: `Straße eq 'GROSS' and 名前 eq '東京'`,
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 7},
+			{Type: lexer.Equals, Start: 8, End: 10},
+			{Type: lexer.SingleQuotedString, Start: 11, End: 18},
+			{Type: lexer.And, Start: 19, End: 22},
+			{Type: lexer.UnquotedString, Start: 23, End: 29},
+			{Type: lexer.Equals, Start: 30, End: 32},
+			{Type: lexer.SingleQuotedString, Start: 33, End: 41},
+		},
+	},
+	{
+		input: "İl　EQ @şehir",
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 3},
+			{Type: lexer.Equals, Start: 6, End: 8},
+			{Type: lexer.ParameterAlias, Start: 9, End: 16},
+		},
+	},
+	{
+		input: `trueCount eq 1 or nullable eq null or order gt falsePositives`,
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 9},
+			{Type: lexer.Equals, Start: 10, End: 12},
+			{Type: lexer.IntegerLiteral, Start: 13, End: 14},
+			{Type: lexer.Or, Start: 15, End: 17},
+			{Type: lexer.UnquotedString, Start: 18, End: 26},
+			{Type: lexer.Equals, Start: 27, End: 29},
+			{Type: lexer.NullLiteral, Start: 30, End: 34},
+			{Type: lexer.Or, Start: 35, End: 37},
+			{Type: lexer.UnquotedString, Start: 38, End: 43},
+			{Type: lexer.GreaterThan, Start: 44, End: 46},
+			{Type: lexer.UnquotedString, Start: 47, End: 61},
+		},
+	},
+	{
+		input: `not(Name eq'Milk')and Name in('Milk')`,
+		expected: []lexer.Token{
+			{Type: lexer.Not, Start: 0, End: 3},
+			{Type: lexer.OpenParens, Start: 3, End: 4},
+			{Type: lexer.UnquotedString, Start: 4, End: 8},
+			{Type: lexer.Equals, Start: 9, End: 11},
+			{Type: lexer.SingleQuotedString, Start: 11, End: 17},
+			{Type: lexer.CloseParens, Start: 17, End: 18},
+			{Type: lexer.And, Start: 18, End: 21},
+			{Type: lexer.UnquotedString, Start: 22, End: 26},
+			{Type: lexer.In, Start: 27, End: 29},
+			{Type: lexer.OpenParens, Start: 29, End: 30},
+			{Type: lexer.SingleQuotedString, Start: 30, End: 36},
+			{Type: lexer.CloseParens, Start: 36, End: 37},
+		},
+	},
+	{
+		input: "dayOfWeek  eq\t1 and Not/Value eq true",
+		expected: []lexer.Token{
+			{Type: lexer.UnquotedString, Start: 0, End: 9},
+			{Type: lexer.Equals, Start: 11, End: 13},
+			{Type: lexer.IntegerLiteral, Start: 14, End: 15},
+			{Type: lexer.And, Start: 16, End: 19},
+			{Type: lexer.UnquotedString, Start: 20, End: 29},
+			{Type: lexer.Equals, Start: 30, End: 32},
+			{Type: lexer.TokenTrue, Start: 33, End: 37},
+		},
+	},
 }
 
 func TestToken(t *testing.T) {
@@ -779,6 +912,91 @@ func TestToken(t *testing.T) {
 	}
 }
 
+func TestNoMatchingToken(t *testing.T) {
+	t.Parallel()
+	const expectedToken = "a property, literal, operator or function"
+	tests := []struct {
+		input    string
+		expected lexer.NoMatchingTokenError
+	}{
+		{"Name eq 'Milk", lexer.NoMatchingTokenError{Position: 8, End: 13, Text: "'Milk", Expected: "' to close the string started at 8"}},
+		{`Name eq "Milk and Price gt 1`, lexer.NoMatchingTokenError{Position: 8, End: 28, Text: `"Milk and Price gt 1`, Expected: `" to close the string started at 8`}},
+		{"Name eq 'İstanbul", lexer.NoMatchingTokenError{Position: 8, End: 18, Text: "'İstanbul", Expected: "' to close the string started at 8"}},
+		{"`a`=1||`b` eq 1", lexer.NoMatchingTokenError{Position: 0, End: 10, Text: "`a`=1||`b`", Expected: expectedToken}},
+		{"Na;me eq 1", lexer.NoMatchingTokenError{Position: 2, End: 5, Text: ";me", Expected: expectedToken}},
+		{"ID eq 1; DROP TABLE Products", lexer.NoMatchingTokenError{Position: 7, End: 8, Text: ";", Expected: expectedToken}},
+		{"Sales. eq 1", lexer.NoMatchingTokenError{Position: 5, End: 6, Text: ".", Expected: expectedToken}},
+		{"Name eq Milk'); --", lexer.NoMatchingTokenError{Position: 12, End: 18, Text: "'); --", Expected: "' to close the string started at 12"}},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			l := lexer.NewLexer(tc.input)
+			var err error
+			for {
+				var token *lexer.Token
+				token, err = l.NextToken()
+				if err != nil {
+					break
+				}
+				if token == nil {
+					t.Fatal("expected an error")
+				}
+			}
+			var noMatch lexer.NoMatchingTokenError
+			if !errors.As(err, &noMatch) {
+				t.Fatalf("expected a NoMatchingTokenError, got %v", err)
+			}
+			if noMatch != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, noMatch)
+			}
+		})
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		literal  string
+		expected string
+	}{
+		{`'Milk'`, "Milk"},
+		{`'O''Neil'`, "O'Neil"},
+		{`''''`, "'"},
+		{`''`, ""},
+		{`'^A.*e$'`, "^A.*e$"},
+		{`'%5EA.*e$'`, "%5EA.*e$"},
+		{`'%27%27'`, "%27%27"},
+		{`'50%'`, "50%"},
+		{`'100%25'`, "100%25"},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.literal, func(t *testing.T) {
+			t.Parallel()
+			l := lexer.NewLexer(tc.literal)
+			token, err := l.NextToken()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if token.Type != lexer.SingleQuotedString || token.End != len(tc.literal) {
+				t.Fatalf("expected one string token, got %v %d-%d", token.Type, token.Start, token.End)
+			}
+			data, err := token.GetData()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if data != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, data)
+			}
+			if value := lexer.UnescapeString(lexer.QuoteString(tc.expected)); value != tc.expected {
+				t.Errorf("expected %q to round trip, got %q", tc.expected, value)
+			}
+		})
+	}
+}
+
 func BenchmarkToken(b *testing.B) {
 	for _, test := range tests {
 		tc := test
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/pboyd04/godata/filter/lexer"
+)
+
+// ResolveAliases returns a copy of the parser with every parameter alias (@name) replaced by its value. The map is
+// keyed by the alias name including the @, values are either literals or JSON objects/arrays.
+func (p *Parser) ResolveAliases(aliases map[string]string) (*Parser, error) {
+	if p.op == nil && !p.hasAliases() {
+		return p, nil
+	}
+	op, err := p.GetOperation()
+	if err != nil {
+		return nil, err
+	}
+	op, err = op.deepClone()
+	if err != nil {
+		return nil, err
+	}
+	err = op.resolveAliases(aliases)
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{lexer: p.lexer, input: p.input, tokens: nil, op: op}, nil
+}
+
+func (p *Parser) hasAliases() bool {
+	for _, token := range p.tokens {
+		if token.Type == lexer.ParameterAlias {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Operation) resolveAliases(aliases map[string]string) error {
+	for i, operand := range o.Operands {
+		switch op := operand.(type) {
+		case *lexer.Token:
+			if op.Type != lexer.ParameterAlias {
+				continue
+			}
+			value, ok := aliases[op.Text]
+			if !ok {
+				return &UnresolvedAliasError{Name: op.Text, Start: op.Start, End: op.End}
+			}
+			resolved, err := parseAliasValue(op, value)
+			if err != nil {
+				return err
+			}
+			o.Operands[i] = resolved
+		case *SliceOperand:
+			err := (&Operation{Operands: op.Slice}).resolveAliases(aliases)
+			if err != nil {
+				return err
+			}
+		case *Operation:
+			err := op.resolveAliases(aliases)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseAliasValue turns the value of the alias token into an operand, errors are located at the token.
+func parseAliasValue(alias *lexer.Token, value string) (Operand, error) {
+	ret, err := aliasValue(alias.Text, value)
+	var parsingErr *ParsingError
+	if errors.As(err, &parsingErr) && parsingErr.End == 0 {
+		return nil, &ParsingError{message: err.Error(), Start: alias.Start, End: alias.End, Text: alias.Text, cause: err}
+	}
+	return ret, err
+}
+
+func aliasValue(name, value string) (Operand, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, newParserError("alias %s has no value", name)
+	}
+	switch value[0] {
+	case '{':
+		if !json.Valid([]byte(value)) {
+			return nil, newParserError("alias %s is not a valid JSON object", name)
+		}
+		return &ObjectOperand{Properties: value}, nil
+	case '[':
+		var arr []interface{}
+		err := json.Unmarshal([]byte(value), &arr)
+		if err != nil {
+			return nil, newParserError("alias %s is not a valid JSON array", name)
+		}
+		ret := &SliceOperand{Slice: make([]Operand, 0, len(arr))}
+		for _, elem := range arr {
+			token, err := jsonLiteralToken(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret.Slice = append(ret.Slice, token)
+		}
+		return ret, nil
+	}
+	// Lexer errors are about the value, not the filter, so they're reported like any other bad value
+	myLexer := lexer.NewLexer(value)
+	token, err := myLexer.NextToken()
+	if err != nil || token == nil {
+		return nil, newParserError("alias %s is not a literal value", name)
+	}
+	next, err := myLexer.NextToken()
+	if err != nil || next != nil || !isLiteral(token) {
+		return nil, newParserError("alias %s is not a literal value", name)
+	}
+	return token, nil
+}
+
+func jsonLiteralToken(value interface{}) (*lexer.Token, error) {
+	switch v := value.(type) {
+	case nil:
+		return &lexer.Token{Type: lexer.NullLiteral, Text: "null"}, nil
+	case bool:
+		if v {
+			return &lexer.Token{Type: lexer.TokenTrue, Text: "true"}, nil
+		}
+		return &lexer.Token{Type: lexer.TokenFalse, Text: "false"}, nil
+	case float64:
+		// JSON doesn't distinguish between ints and floats
+		token := &lexer.Token{}
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt32 {
+			return token, token.Replace(int(v))
+		}
+		return token, token.Replace(v)
+	case string:
+		token := &lexer.Token{Type: lexer.SingleQuotedString}
+		return token, token.Replace(v)
+	default:
+		return nil, newParserError("unsupported alias array value %v", value)
+	}
+}
+
+func isLiteral(token *lexer.Token) bool {
+	switch token.Type {
+	case lexer.TokenTrue, lexer.TokenFalse, lexer.SingleQuotedString, lexer.DoubleQuotedString, lexer.NullLiteral,
+		lexer.FloatingPointLiteral, lexer.IntegerLiteral, lexer.DateTimeOffsetLiteral, lexer.DateLiteral, lexer.GUIDLiteral:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/pboyd04/godata/errcode"
+	"github.com/pboyd04/godata/filter/lexer"
+)
+
+// Complexity describes the size of a filter. It's worked out from the tokens, before they're turned into an
+// expression tree, so it's cheap enough to check against limits before doing anything else with the filter.
+type Complexity struct {
+	// Depth is the deepest nesting of parentheses and brackets.
+	Depth int
+	// LargestInList is the number of values in the biggest in list.
+	LargestInList int
+	// Functions are the functions used, in the order they appear.
+	Functions []Function
+}
+
+// Function is a function call in a filter.
+type Function struct {
+	// Name is the lower case name of the function, i.e. contains.
+	Name string
+	// Span is where the function name is in the filter.
+	Span errcode.Span
+}
+
+// Complexity measures the filter, a parser that has already been turned into an expression tree (i.e. by
+// ResolveAliases) has no tokens left and measures as empty.
+func (p *Parser) Complexity() Complexity {
+	ret := Complexity{}
+	depth := 0
+	// lists holds the number of values seen so far in each open in list, -1 for groups that aren't in lists
+	lists := []int{}
+	for i, token := range p.tokens {
+		//nolint:exhaustive // Only grouping, commas and functions matter
+		switch token.Type {
+		case lexer.OpenParens, lexer.OpenSquareBracket:
+			depth++
+			if depth > ret.Depth {
+				ret.Depth = depth
+			}
+			if i > 0 && p.tokens[i-1].Type == lexer.In {
+				lists = append(lists, 0)
+			} else {
+				lists = append(lists, -1)
+			}
+		case lexer.CloseParens, lexer.CloseSquareBracket:
+			depth--
+			if len(lists) == 0 {
+				continue
+			}
+			if count := lists[len(lists)-1]; count >= 0 && i > 0 && !isOpen(p.tokens[i-1]) && count+1 > ret.LargestInList {
+				ret.LargestInList = count + 1
+			}
+			lists = lists[:len(lists)-1]
+		case lexer.Comma:
+			if len(lists) > 0 && lists[len(lists)-1] >= 0 {
+				lists[len(lists)-1]++
+			}
+		default:
+			if token.HasParameters() {
+				ret.Functions = append(ret.Functions, Function{Name: strings.ToLower(token.Text), Span: errcode.Span{Start: token.Start, End: token.End}})
+			}
+		}
+	}
+	return ret
+}
+
+func isOpen(token *lexer.Token) bool {
+	return token.Type == lexer.OpenParens || token.Type == lexer.OpenSquareBracket
+}
+
+// TreeSize is the size of a filter's expression tree. Unlike Complexity it's measured once parameter aliases are
+// resolved, so an alias holding a large array counts in full.
+type TreeSize struct {
+	// Nodes is the number of operations and operands, every value in a list counts as one.
+	Nodes int
+	// LargestInList is the number of values in the biggest in list.
+	LargestInList int
+}
+
+// TreeSize builds the expression tree, if it isn't built already, and measures it.
+func (p *Parser) TreeSize() (TreeSize, error) {
+	op, err := p.GetOperation()
+	if err != nil {
+		return TreeSize{}, err
+	}
+	ret := TreeSize{}
+	ret.add(op)
+	return ret, nil
+}
+
+func (s *TreeSize) add(operand Operand) {
+	s.Nodes++
+	switch data := operand.(type) {
+	case *Operation:
+		if data.Operator == lexer.In && len(data.Operands) == 2 {
+			if list, ok := data.Operands[1].(*SliceOperand); ok && len(list.Slice) > s.LargestInList {
+				s.LargestInList = len(list.Slice)
+			}
+		}
+		for _, child := range data.Operands {
+			s.add(child)
+		}
+	case *SliceOperand:
+		// The list itself isn't a node, only its values are
+		s.Nodes--
+		for _, child := range data.Slice {
+			s.add(child)
+		}
+	}
+}
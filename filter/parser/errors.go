@@ -1,18 +1,150 @@
 package parser
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/pboyd04/godata/errcode"
+	"github.com/pboyd04/godata/filter/lexer"
+)
+
+// ParsingError is returned when a filter can't be turned into an expression tree. Start and End are the byte offsets
+// of the offending part of the filter and Text is that part, Expected describes what should have been there when
+// that's known.
 type ParsingError struct {
-	message string
+	message  string
+	Start    int
+	End      int
+	Text     string
+	Expected string
+	// cause is the error this one locates, so errors.Is still finds the sentinel errors
+	cause error
 }
 
 func (e *ParsingError) Error() string {
 	return e.message
 }
 
+func (e *ParsingError) Unwrap() error {
+	return e.cause
+}
+
+func (e *ParsingError) ErrorSpan() errcode.Span {
+	return errcode.Span{Start: e.Start, End: e.End}
+}
+
 func newParserError(format string, a ...interface{}) error {
 	return &ParsingError{message: fmt.Sprintf(format, a...)}
 }
 
+// newParserErrorAt is a ParsingError located at a token.
+func newParserErrorAt(token *lexer.Token, format string, a ...interface{}) error {
+	return &ParsingError{message: fmt.Sprintf(format, a...), Start: token.Start, End: token.End, Text: token.Text}
+}
+
+// newTokenError is a ParsingError about a token, the message is expected plus where and what was found instead.
+func newTokenError(token *lexer.Token, expected string) error {
+	return &ParsingError{
+		message:  fmt.Sprintf("expected %s, found %s at %d", expected, quoted(token), token.Start),
+		Start:    token.Start,
+		End:      token.Start + len(tokenText(token)),
+		Text:     tokenText(token),
+		Expected: expected,
+	}
+}
+
+// newEndError is a ParsingError for a filter that stops before it should, at is its length.
+func newEndError(at int, expected string) error {
+	return &ParsingError{
+		message:  fmt.Sprintf("expected %s, found end of filter at %d", expected, at),
+		Start:    at,
+		End:      at,
+		Expected: expected,
+	}
+}
+
+// located returns a copy of err covering the whole filter, for errors from building the expression tree that aren't
+// about one token.
+func located(err error, input string) error {
+	var parsingErr *ParsingError
+	if !errors.As(err, &parsingErr) || parsingErr.End != 0 || input == "" {
+		return err
+	}
+	return &ParsingError{message: err.Error(), Start: 0, End: len(input), Text: input, cause: err}
+}
+
+// UnresolvedAliasError is returned when a parameter alias is used without a value being supplied for it.
+type UnresolvedAliasError struct {
+	Name  string
+	Start int
+	End   int
+}
+
+func (e *UnresolvedAliasError) Error() string {
+	return "unresolved parameter alias " + e.Name
+}
+
+// UnboundPlaceholderError is returned by Bind when a placeholder has no value.
+type UnboundPlaceholderError struct {
+	Name  string
+	Start int
+	End   int
+}
+
+func (e *UnboundPlaceholderError) Error() string {
+	return "placeholder :" + e.Name + " is not bound"
+}
+
+// InvalidPlaceholderValueError is returned when the value for a placeholder can't be used.
+type InvalidPlaceholderValueError struct {
+	Name  string
+	Value interface{}
+	Err   error
+	Start int
+	End   int
+}
+
+func (e *InvalidPlaceholderValueError) Error() string {
+	return fmt.Sprintf("invalid value %v (%T) for placeholder :%s: %s", e.Value, e.Value, e.Name, e.Err)
+}
+
+func (e *InvalidPlaceholderValueError) Unwrap() error {
+	return e.Err
+}
+
+var ErrSliceOutsideIn = newParserError("slices can only be used in an in list")
 var ErrMoreThanOneChild = newParserError("more than one child")
 var ErrNoSuchLanguage = newParserError("no such language")
+
+func (e *ParsingError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
+
+func (e *UnresolvedAliasError) ErrorCode() errcode.Code {
+	return errcode.UnresolvedAlias
+}
+
+func (e *UnboundPlaceholderError) ErrorCode() errcode.Code {
+	return errcode.InvalidValue
+}
+
+func (e *InvalidPlaceholderValueError) ErrorCode() errcode.Code {
+	return errcode.InvalidValue
+}
+
+func (e *UnresolvedAliasError) ErrorSpan() errcode.Span {
+	return errcode.Span{Start: e.Start, End: e.End}
+}
+
+func (e *UnboundPlaceholderError) ErrorSpan() errcode.Span {
+	return errcode.Span{Start: e.Start, End: e.End}
+}
+
+func (e *InvalidPlaceholderValueError) ErrorSpan() errcode.Span {
+	return errcode.Span{Start: e.Start, End: e.End}
+}
+
+func tokenText(token *lexer.Token) string {
+	return strings.TrimSpace(token.Text)
+}
@@ -0,0 +1,200 @@
+package golang
+
+import (
+	"sort"
+	"strings"
+
+	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/filter/lexer"
+	"github.com/pboyd04/godata/filter/parser"
+	"github.com/pboyd04/godata/orderby"
+)
+
+// Result is the outcome of applying QueryOptions to a slice.
+type Result[T any] struct {
+	// Items are the items left after filtering, sorting and paging.
+	Items []T
+	// Selected holds the $select projection of each item in Items, it's nil if there was no $select.
+	Selected []map[string]interface{}
+	// Count is the number of items that passed the filter before paging, it's nil unless $count=true.
+	Count *int64
+}
+
+// Apply runs $filter, $orderby, $skip, $top, $select and $count against an in-memory slice, in that order. Like the
+// gorm helpers a negative $top is treated as not being set, so a $top of 0 returns no items.
+func Apply[T any](opts *odata.QueryOptions, items []T, evalOpts ...Option) (*Result[T], error) {
+	ret := &Result[T]{Items: items}
+	if opts == nil {
+		return ret, nil
+	}
+	if opts.Filter != nil {
+		filtered, err := Filter(opts.Filter, items, evalOpts...)
+		if err != nil {
+			return nil, err
+		}
+		ret.Items = filtered
+	} else {
+		// Sorting happens in place, don't reorder the caller's slice
+		ret.Items = append(make([]T, 0, len(items)), items...)
+	}
+	if opts.Count {
+		count := int64(len(ret.Items))
+		ret.Count = &count
+	}
+	if opts.OrderBy != nil && len(opts.OrderBy.OrderItem) > 0 {
+		err := sortItems(ret.Items, opts.OrderBy.OrderItem)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret.Items = page(ret.Items, opts.Skip, opts.Top)
+	if opts.Select != nil {
+		selected, err := project(ret.Items, *opts.Select)
+		if err != nil {
+			return nil, err
+		}
+		ret.Selected = selected
+	}
+	return ret, nil
+}
+
+func page[T any](items []T, skip, top int64) []T {
+	if skip > 0 {
+		if skip >= int64(len(items)) {
+			return items[:0]
+		}
+		items = items[skip:]
+	}
+	if top >= 0 && top < int64(len(items)) {
+		items = items[:top]
+	}
+	return items
+}
+
+// propertyValue looks up a property, which may be a path like Address/City, on the current row.
+func (r *row) propertyValue(property string) (interface{}, error) {
+	path := &parser.PropertyPath{Segments: strings.Split(property, "/")}
+	value, err := r.field(path.Segments[0])
+	if err != nil || len(path.Segments) == 1 {
+		return value, err
+	}
+	return walkPath(value, path)
+}
+
+func sortItems[T any](items []T, order []orderby.OrderItem) error {
+	// Look the keys up once rather than on every comparison
+	keys := make([][]interface{}, len(items))
+	r := new(row)
+	for i, item := range items {
+		r.reset(item)
+		keys[i] = make([]interface{}, len(order))
+		for j, orderItem := range order {
+			value, err := r.propertyValue(strings.TrimSpace(orderItem.Property))
+			if err != nil {
+				return err
+			}
+			keys[i][j] = value
+		}
+	}
+	indexes := make([]int, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	var sortErr error
+	sort.SliceStable(indexes, func(a, b int) bool {
+		for j, orderItem := range order {
+			cmp, err := compareForSort(keys[indexes[a]][j], keys[indexes[b]][j])
+			if err != nil {
+				sortErr = withContext(err, lexer.LessThan, orderItem.Property)
+				return false
+			}
+			if cmp == 0 {
+				continue
+			}
+			if orderItem.Direction == orderby.DESC {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+	sorted := make([]T, len(items))
+	for i, index := range indexes {
+		sorted[i] = items[index]
+	}
+	copy(items, sorted)
+	return nil
+}
+
+// compareForSort compares two values, null sorts before everything else.
+func compareForSort(a, b interface{}) (int, error) {
+	switch {
+	case isNil(a) && isNil(b):
+		return 0, nil
+	case isNil(a):
+		return -1, nil
+	case isNil(b):
+		return 1, nil
+	}
+	if aVal, ok := a.(bool); ok {
+		bVal, ok := b.(bool)
+		if !ok {
+			return 0, newTypeMismatchError(a, b)
+		}
+		if aVal == bVal {
+			return 0, nil
+		}
+		if aVal {
+			return 1, nil
+		}
+		return -1, nil
+	}
+	return compare(a, b)
+}
+
+func project[T any](items []T, selects []string) ([]map[string]interface{}, error) {
+	ret := make([]map[string]interface{}, len(items))
+	r := new(row)
+	for i, item := range items {
+		r.reset(item)
+		ret[i] = make(map[string]interface{}, len(selects))
+		for _, property := range selects {
+			property = strings.TrimSpace(property)
+			if property == "*" {
+				err := r.allFields(ret[i])
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			value, err := r.propertyValue(property)
+			if err != nil {
+				return nil, err
+			}
+			ret[i][property] = value
+		}
+	}
+	return ret, nil
+}
+
+// allFields copies every field of the row into the map.
+func (r *row) allFields(dest map[string]interface{}) error {
+	if r.m != nil {
+		for k, v := range r.m {
+			dest[k] = v
+		}
+		return nil
+	}
+	if r.accessor == nil {
+		_, err := r.field("*")
+		return err
+	}
+	for name := range r.accessor.fields {
+		value, _ := r.accessor.field(r.value, name)
+		dest[name] = value
+	}
+	return nil
+}
@@ -0,0 +1,588 @@
+package golang
+
+import (
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/pboyd04/godata/filter/lexer"
+	"github.com/pboyd04/godata/filter/parser"
+	"github.com/shopspring/decimal"
+)
+
+// The operation tree is compiled once into a tree of closures, so evaluating a row doesn't need to walk the tree,
+// call GetData or reflect over the row's type again.
+
+// compiledFn evaluates part of a filter against a row. Predicates return a bool, everything else returns its value.
+type compiledFn func(r *row) (interface{}, error)
+
+// row is a single item being filtered.
+type row struct {
+	value    reflect.Value
+	myType   reflect.Type
+	accessor *typeAccessor
+	m        map[string]interface{}
+}
+
+// typeAccessor holds the field indexes of a struct type by the name used in filters.
+type typeAccessor struct {
+	fields map[string][]int
+}
+
+//nolint:gochecknoglobals // The accessors are cached per type for the life of the program
+var accessors sync.Map
+
+func accessorFor(myType reflect.Type) *typeAccessor {
+	cached, ok := accessors.Load(myType)
+	if ok {
+		//nolint:forcetypeassert // Only typeAccessors are stored
+		return cached.(*typeAccessor)
+	}
+	accessor := &typeAccessor{fields: make(map[string][]int)}
+	for _, field := range reflect.VisibleFields(myType) {
+		accessor.fields[fieldName(field)] = field.Index
+	}
+	cached, _ = accessors.LoadOrStore(myType, accessor)
+	//nolint:forcetypeassert // Only typeAccessors are stored
+	return cached.(*typeAccessor)
+}
+
+// field returns the value of a struct field, a missing field is reported by the second return value.
+func (a *typeAccessor) field(value reflect.Value, name string) (interface{}, bool) {
+	index, ok := a.fields[name]
+	if !ok {
+		return nil, false
+	}
+	fieldValue, err := value.FieldByIndexErr(index)
+	if err != nil {
+		// Promoted through a nil embedded pointer
+		return nil, true
+	}
+	return deref(fieldValue), true
+}
+
+// deref follows the pointers nullable fields are usually declared with, a nil pointer is null.
+func deref(value reflect.Value) interface{} {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil
+	}
+	return value.Interface()
+}
+
+// reset points the row at a new item, the accessor is only looked up again if the type changes.
+func (r *row) reset(item interface{}) {
+	r.m = nil
+	myMap, ok := item.(map[string]interface{})
+	if ok {
+		r.m = myMap
+		r.myType = nil
+		r.accessor = nil
+		return
+	}
+	r.value = reflect.ValueOf(item)
+	if r.value.Kind() == reflect.Pointer && !r.value.IsNil() {
+		r.value = r.value.Elem()
+	}
+	if r.value.Kind() != reflect.Struct {
+		r.myType = nil
+		r.accessor = nil
+		return
+	}
+	if r.value.Type() != r.myType {
+		r.myType = r.value.Type()
+		r.accessor = accessorFor(r.myType)
+	}
+}
+
+func (r *row) field(name string) (interface{}, error) {
+	if r.m != nil {
+		// Maps don't have a schema, so a missing key is just null
+		return deref(reflect.ValueOf(r.m[name])), nil
+	}
+	if r.accessor != nil {
+		value, ok := r.accessor.field(r.value, name)
+		if ok {
+			return value, nil
+		}
+		return nil, &UnknownFieldError{field: name}
+	}
+	if !r.value.IsValid() {
+		return nil, &UnsupportedDataTypeError{Type: "nil"}
+	}
+	return nil, &UnsupportedDataTypeError{Type: r.value.Type().String()}
+}
+
+func truthy(value interface{}) bool {
+	b, ok := value.(bool)
+	if ok {
+		return b
+	}
+	return value != nil
+}
+
+// logical is the value of a non-null operand of and, or and not. OData has no truthiness, anything but a bool is an
+// error.
+func logical(value interface{}, operator lexer.TokenKey, field string) (bool, error) {
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Bool {
+		return v.Bool(), nil
+	}
+	return false, withContext(newUnsupportedOperandTypeError(value), operator, field)
+}
+
+func constant(value interface{}) compiledFn {
+	return func(_ *row) (interface{}, error) {
+		return value, nil
+	}
+}
+
+//nolint:cyclop // This is just a big type switch
+func compileOperand(operand parser.Operand) (compiledFn, error) {
+	switch op := operand.(type) {
+	case *lexer.Token:
+		if op.Type == lexer.UnquotedString {
+			name := op.Text
+			return func(r *row) (interface{}, error) {
+				return r.field(name)
+			}, nil
+		}
+		data, err := op.GetData()
+		if err != nil {
+			return nil, err
+		}
+		return constant(data), nil
+	case *parser.PropertyPath:
+		return func(r *row) (interface{}, error) {
+			value, err := r.field(op.Segments[0])
+			if err != nil {
+				return nil, &UnknownFieldError{field: op.String()}
+			}
+			return walkPath(value, op)
+		}, nil
+	case *parser.SliceOperand:
+		fns, err := compileOperands(op.Slice)
+		if err != nil {
+			return nil, err
+		}
+		if isConstantSlice(op) {
+			// Nothing in the list depends on the row, so it only needs to be built once
+			values, err := evaluateAll(fns, nil)
+			if err != nil {
+				return nil, err
+			}
+			return constant(values), nil
+		}
+		return func(r *row) (interface{}, error) {
+			return evaluateAll(fns, r)
+		}, nil
+	case *parser.ObjectOperand:
+		data, err := op.GetData()
+		if err != nil {
+			return nil, err
+		}
+		return constant(data), nil
+	case *parser.Operation:
+		return compileOperation(op)
+	default:
+		return nil, newUnsupportedOperandError(operand)
+	}
+}
+
+func compileOperands(operands []parser.Operand) ([]compiledFn, error) {
+	ret := make([]compiledFn, len(operands))
+	for i, operand := range operands {
+		fn, err := compileOperand(operand)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = fn
+	}
+	return ret, nil
+}
+
+func isConstantSlice(slice *parser.SliceOperand) bool {
+	for _, operand := range slice.Slice {
+		token, ok := operand.(*lexer.Token)
+		if !ok || token.Type == lexer.UnquotedString {
+			return false
+		}
+	}
+	return true
+}
+
+func evaluateAll(fns []compiledFn, r *row) ([]interface{}, error) {
+	ret := make([]interface{}, len(fns))
+	for i, fn := range fns {
+		value, err := fn(r)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = value
+	}
+	return ret, nil
+}
+
+//nolint:cyclop // This function is complex because it has to handle all the different types of operations
+func compileOperation(op *parser.Operation) (compiledFn, error) {
+	//nolint:exhaustive // Anything not listed is either a computed value or unsupported
+	switch op.Operator {
+	case lexer.TokenTrue, parser.NoOp:
+		return constant(true), nil
+	case lexer.TokenFalse:
+		return constant(false), nil
+	case lexer.Case:
+		return compileCase(op)
+	case lexer.And, lexer.Or:
+		return compileConjunction(op)
+	case lexer.Not:
+		if len(op.Operands) != 1 {
+			return nil, newParserError("not requires exactly one operand")
+		}
+		operand, err := compileOperand(op.Operands[0])
+		if err != nil {
+			return nil, err
+		}
+		field := fieldOf(op.Operands[0])
+		return func(r *row) (interface{}, error) {
+			value, err := operand(r)
+			if err != nil || isNil(value) {
+				// not null is null
+				return nil, err
+			}
+			b, err := logical(value, lexer.Not, field)
+			if err != nil {
+				return nil, err
+			}
+			return !b, nil
+		}, nil
+	case lexer.In:
+		return compileIn(op)
+	case lexer.MatchesPattern:
+		return compileMatchesPattern(op)
+	}
+	compareFn, ok := comparisonMap[lexer.TokenKey(op.Operator)]
+	if ok {
+		return compileComparison(op, compareFn)
+	}
+	opMapFn, ok := opMap[lexer.TokenKey(op.Operator)]
+	if ok {
+		return compileComputation(op, opMapFn)
+	}
+	return nil, &UnsupportedOperatorError{operator: lexer.TokenKey(op.Operator)}
+}
+
+func compileBinary(op *parser.Operation) (compiledFn, compiledFn, error) {
+	if len(op.Operands) != 2 {
+		return nil, nil, newParserError(lexer.TokenKey(op.Operator).String() + " requires exactly two operands")
+	}
+	lhs, err := compileOperand(op.Operands[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	rhs, err := compileOperand(op.Operands[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return lhs, rhs, nil
+}
+
+func compileComparison(op *parser.Operation, compareFn comparisonFn) (compiledFn, error) {
+	lhs, rhs, err := compileBinary(op)
+	if err != nil {
+		return nil, err
+	}
+	operator := lexer.TokenKey(op.Operator)
+	field := fieldOf(op)
+	return func(r *row) (interface{}, error) {
+		a, err := lhs(r)
+		if err != nil {
+			return nil, err
+		}
+		b, err := rhs(r)
+		if err != nil {
+			return nil, err
+		}
+		ret, err := compareFn(a, b)
+		if err != nil {
+			return nil, withContext(err, operator, field)
+		}
+		return ret, nil
+	}, nil
+}
+
+func compileConjunction(op *parser.Operation) (compiledFn, error) {
+	lhs, rhs, err := compileBinary(op)
+	if err != nil {
+		return nil, err
+	}
+	operator := lexer.TokenKey(op.Operator)
+	isOr := operator == lexer.Or
+	lhsField, rhsField := fieldOf(op.Operands[0]), fieldOf(op.Operands[1])
+	// Three valued logic, null only decides the result if the other side doesn't
+	return func(r *row) (interface{}, error) {
+		a, err := lhs(r)
+		if err != nil {
+			return nil, err
+		}
+		if !isNil(a) {
+			value, err := logical(a, operator, lhsField)
+			if err != nil {
+				return nil, err
+			}
+			if value == isOr {
+				// Short circuit, the other side can't change the result
+				return isOr, nil
+			}
+		}
+		b, err := rhs(r)
+		if err != nil {
+			return nil, err
+		}
+		if !isNil(b) {
+			value, err := logical(b, operator, rhsField)
+			if err != nil {
+				return nil, err
+			}
+			if value == isOr {
+				return isOr, nil
+			}
+		}
+		if isNil(a) || isNil(b) {
+			return nil, nil
+		}
+		return !isOr, nil
+	}, nil
+}
+
+func compileIn(op *parser.Operation) (compiledFn, error) {
+	lhs, rhs, err := compileBinary(op)
+	if err != nil {
+		return nil, err
+	}
+	field := fieldOf(op)
+	return func(r *row) (interface{}, error) {
+		value, err := lhs(r)
+		if err != nil {
+			return nil, err
+		}
+		list, err := rhs(r)
+		if err != nil {
+			return nil, err
+		}
+		ret, err := contains(list, value)
+		if err != nil {
+			return nil, withContext(err, lexer.In, field)
+		}
+		return ret, nil
+	}, nil
+}
+
+func compileMatchesPattern(op *parser.Operation) (compiledFn, error) {
+	if len(op.Operands) != 2 {
+		return compileComparison(op, comparisonMap[lexer.MatchesPattern])
+	}
+	token, ok := op.Operands[1].(*lexer.Token)
+	if !ok || token.Type == lexer.UnquotedString {
+		// The pattern comes from the row, so it has to be compiled every time
+		return compileComparison(op, comparisonMap[lexer.MatchesPattern])
+	}
+	pattern, err := token.GetData()
+	if err != nil {
+		return nil, err
+	}
+	patternStr, ok := pattern.(string)
+	if !ok {
+		return nil, newUnsupportedOperandError(pattern)
+	}
+	reg, err := regexp.Compile(patternStr)
+	if err != nil {
+		return nil, err
+	}
+	lhs, err := compileOperand(op.Operands[0])
+	if err != nil {
+		return nil, err
+	}
+	field := fieldOf(op)
+	return func(r *row) (interface{}, error) {
+		value, err := lhs(r)
+		if err != nil {
+			return nil, err
+		}
+		ret, err := matchesPattern(reg, value)
+		if err != nil {
+			return nil, withContext(err, lexer.MatchesPattern, field)
+		}
+		return ret, nil
+	}, nil
+}
+
+func compileComputation(op *parser.Operation, opMapFn opFunction) (compiledFn, error) {
+	if len(op.Operands) < 1 {
+		return nil, newParserError("computed operations require at least one operand")
+	}
+	fns, err := compileOperands(op.Operands)
+	if err != nil {
+		return nil, err
+	}
+	operator := lexer.TokenKey(op.Operator)
+	field := fieldOf(op)
+	return func(r *row) (interface{}, error) {
+		values, err := evaluateAll(fns, r)
+		if err != nil {
+			return nil, err
+		}
+		ret, err := opMapFn(values[0], values[1:]...)
+		if err != nil {
+			return nil, withContext(err, operator, field)
+		}
+		return ret, nil
+	}, nil
+}
+
+func compileCase(op *parser.Operation) (compiledFn, error) {
+	if len(op.Operands)%2 != 0 {
+		return nil, newParserError("case requires condition:value pairs")
+	}
+	fns, err := compileOperands(op.Operands)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *row) (interface{}, error) {
+		for i := 0; i+1 < len(fns); i += 2 {
+			cond, err := fns[i](r)
+			if err != nil {
+				return nil, err
+			}
+			if truthy(cond) {
+				return fns[i+1](r)
+			}
+		}
+		// No branch matched so the result is null
+		return nil, nil
+	}, nil
+}
+
+// contains reports whether value is in the list, comparing the elements the way eq does so an int64 field matches an
+// int literal.
+func contains(list interface{}, value interface{}) (bool, error) {
+	values, err := toInterfaceSlice(list)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range values {
+		ok, err := equals(value, v)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isNumber is true for any int, uint or float kind, including named types such as type Quantity int64.
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	case nil, string, bool:
+		return false
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+		reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// normalizeNumber turns any int or uint kind into an int and any float kind into a float64, the kinds literals have, so
+// the functions only need to handle those two. Anything else is returned as it is.
+func normalizeNumber(value interface{}) interface{} {
+	switch value.(type) {
+	case int, float64, nil:
+		return value
+	}
+	if i, ok := toInt64(value); ok {
+		return int(i)
+	}
+	if isNumber(value) {
+		ret, _ := toDecimal(value).Float64()
+		return ret
+	}
+	return value
+}
+
+// toInt64 converts any signed or unsigned int, the second return value is false for floats.
+func toInt64(value interface{}) (int64, bool) {
+	myValue := reflect.ValueOf(value)
+	switch myValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return myValue.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		//nolint:gosec // Values that don't fit in an int64 are rare enough to ignore
+		return int64(myValue.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+func toFloat64(value interface{}) float64 {
+	switch val := value.(type) {
+	case float64:
+		return val
+	case float32:
+		return float64(val)
+	}
+	if i, ok := toInt64(value); ok {
+		return float64(i)
+	}
+	return reflect.ValueOf(value).Float()
+}
+
+func toDecimal(value interface{}) decimal.Decimal {
+	switch val := value.(type) {
+	case float64:
+		return decimal.NewFromFloat(val)
+	case float32:
+		return decimal.NewFromFloat32(val)
+	}
+	if i, ok := toInt64(value); ok {
+		return decimal.NewFromInt(i)
+	}
+	myValue := reflect.ValueOf(value)
+	if myValue.Kind() == reflect.Float32 {
+		return decimal.NewFromFloat32(float32(myValue.Float()))
+	}
+	return decimal.NewFromFloat(myValue.Float())
+}
+
+// fieldOf returns the first field used by an operation, used to add context to errors.
+func fieldOf(operand parser.Operand) string {
+	switch op := operand.(type) {
+	case *lexer.Token:
+		if op.Type == lexer.UnquotedString {
+			return op.Text
+		}
+	case *parser.PropertyPath:
+		return op.String()
+	case *parser.Operation:
+		for _, child := range op.Operands {
+			field := fieldOf(child)
+			if field != "" {
+				return field
+			}
+		}
+	}
+	return ""
+}
@@ -1,8 +1,10 @@
 package golang
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/pboyd04/godata/errcode"
 	"github.com/pboyd04/godata/filter/lexer"
 )
 
@@ -15,6 +17,7 @@ type UnsupportedOperatorError struct {
 }
 
 type UnsupportedDataTypeError struct {
+	Type string
 }
 
 type UnknownFieldError struct {
@@ -34,7 +37,10 @@ func newUnsupportedOperandError(operand interface{}) error {
 }
 
 func (e *UnsupportedDataTypeError) Error() string {
-	return "unsupported data type"
+	if e.Type == "" {
+		return "unsupported data type"
+	}
+	return "unsupported data type: " + e.Type
 }
 
 func (e *ParserError) Error() string {
@@ -52,3 +58,86 @@ func (e *UnknownFieldError) Error() string {
 func (e *UnsupportedOperatorError) Error() string {
 	return "unsupported operator: " + e.operator.String()
 }
+
+// TypeMismatchError is returned when an operator is given two values that can't be used together, i.e. Price eq 'abc'.
+type TypeMismatchError struct {
+	Operator  lexer.TokenKey
+	Field     string
+	LeftType  string
+	RightType string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("type mismatch: %s can't be applied to %s and %s%s", e.Operator, e.LeftType, e.RightType, fieldSuffix(e.Field))
+}
+
+func newTypeMismatchError(left, right interface{}) *TypeMismatchError {
+	return &TypeMismatchError{LeftType: fmt.Sprintf("%T", left), RightType: fmt.Sprintf("%T", right)}
+}
+
+// UnsupportedOperandTypeError is returned when an operator can't handle the type of a value, i.e. year(Name).
+type UnsupportedOperandTypeError struct {
+	Operator lexer.TokenKey
+	Field    string
+	Type     string
+}
+
+func (e *UnsupportedOperandTypeError) Error() string {
+	return fmt.Sprintf("unsupported operand type: %s can't be applied to %s%s", e.Operator, e.Type, fieldSuffix(e.Field))
+}
+
+func newUnsupportedOperandTypeError(value interface{}) *UnsupportedOperandTypeError {
+	return &UnsupportedOperandTypeError{Type: fmt.Sprintf("%T", value)}
+}
+
+var ErrDivisionByZero = newParserError("division by zero")
+
+func fieldSuffix(field string) string {
+	if field == "" {
+		return ""
+	}
+	return " (field " + field + ")"
+}
+
+// withContext fills in the operator and field of the type errors, which aren't known where the error is created.
+func withContext(err error, operator lexer.TokenKey, field string) error {
+	var mismatch *TypeMismatchError
+	if errors.As(err, &mismatch) && mismatch.Field == "" {
+		mismatch.Operator = operator
+		mismatch.Field = field
+	}
+	var unsupported *UnsupportedOperandTypeError
+	if errors.As(err, &unsupported) && unsupported.Field == "" {
+		unsupported.Operator = operator
+		unsupported.Field = field
+	}
+	return err
+}
+
+func (e *UnsupportedOperandError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
+
+func (e *UnsupportedOperatorError) ErrorCode() errcode.Code {
+	return errcode.UnsupportedFunction
+}
+
+func (e *UnsupportedDataTypeError) ErrorCode() errcode.Code {
+	return errcode.TypeMismatch
+}
+
+func (e *UnknownFieldError) ErrorCode() errcode.Code {
+	return errcode.UnknownProperty
+}
+
+func (e *ParserError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
+
+func (e *TypeMismatchError) ErrorCode() errcode.Code {
+	return errcode.TypeMismatch
+}
+
+func (e *UnsupportedOperandTypeError) ErrorCode() errcode.Code {
+	return errcode.TypeMismatch
+}
@@ -0,0 +1,120 @@
+package golang
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pboyd04/godata/filter/lexer"
+	"github.com/pboyd04/godata/filter/parser"
+)
+
+// Interpreter is the evaluator FilterSlice used before filters were compiled to closures, cut down to the operators
+// BenchmarkFilterSlice uses. Every call copies every field of every item into a map, then walks the operation tree
+// for each item calling GetData on every operand, which is the work compiling does once. It's only kept so the
+// benchmarks can compare the two.
+type Interpreter struct {
+	op *parser.Operation
+}
+
+func NewInterpreter(op *parser.Operation) *Interpreter {
+	return &Interpreter{op: op}
+}
+
+func (e *Interpreter) FilterSlice(data []interface{}) ([]interface{}, error) {
+	ret := make([]interface{}, 0)
+	for i, fields := range interpreterState(data) {
+		value, err := interpret(e.op, fields)
+		if err != nil {
+			return nil, err
+		}
+		if value == true {
+			ret = append(ret, data[i])
+		}
+	}
+	return ret, nil
+}
+
+func interpreterState(data []interface{}) []map[string]interface{} {
+	ret := make([]map[string]interface{}, len(data))
+	for i, item := range data {
+		fields := make(map[string]interface{})
+		myValue := reflect.ValueOf(item)
+		switch myValue.Kind() {
+		case reflect.Struct:
+			for _, field := range reflect.VisibleFields(myValue.Type()) {
+				fields[fieldName(field)] = myValue.FieldByIndex(field.Index).Interface()
+			}
+		case reflect.Map:
+			for _, key := range myValue.MapKeys() {
+				fields[key.String()] = myValue.MapIndex(key).Interface()
+			}
+		}
+		ret[i] = fields
+	}
+	return ret
+}
+
+func interpretOperand(operand parser.Operand, fields map[string]interface{}) (interface{}, error) {
+	data, err := operand.GetData()
+	if err != nil {
+		return nil, err
+	}
+	switch value := data.(type) {
+	case *parser.Operation:
+		return interpret(value, fields)
+	case *parser.PropertyPath:
+		return walkPath(fields[value.Segments[0]], value)
+	case []parser.Operand:
+		ret := make([]interface{}, len(value))
+		for i, elem := range value {
+			ret[i], err = interpretOperand(elem, fields)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	case string:
+		if token, ok := operand.(*lexer.Token); ok && token.Type == lexer.UnquotedString {
+			return fields[value], nil
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+//nolint:cyclop // One case per operator
+func interpret(op *parser.Operation, fields map[string]interface{}) (interface{}, error) {
+	operands := make([]interface{}, len(op.Operands))
+	for i, operand := range op.Operands {
+		value, err := interpretOperand(operand, fields)
+		if err != nil {
+			return nil, err
+		}
+		operands[i] = value
+	}
+	//nolint:exhaustive // Only the operators the benchmarks use
+	switch lexer.TokenKey(op.Operator) {
+	case lexer.And:
+		return operands[0] == true && operands[1] == true, nil
+	case lexer.Or:
+		return operands[0] == true || operands[1] == true, nil
+	case lexer.Equals:
+		return equals(operands[0], operands[1])
+	case lexer.GreaterThan, lexer.LessThan:
+		cmp, err := compare(operands[0], operands[1])
+		if lexer.TokenKey(op.Operator) == lexer.LessThan {
+			cmp = -cmp
+		}
+		return cmp > 0, err
+	case lexer.Contains:
+		return stringCompare(operands[0], operands[1], strings.Contains)
+	case lexer.Length:
+		str, _ := operands[0].(string)
+		return len(str), nil
+	case lexer.In:
+		return contains(operands[1], operands[0])
+	default:
+		return nil, &UnsupportedOperatorError{operator: lexer.TokenKey(op.Operator)}
+	}
+}
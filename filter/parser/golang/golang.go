@@ -1,18 +1,15 @@
 package golang
 
 import (
-	"log"
 	"reflect"
 	"regexp"
 	"strings"
 	"time"
-	"unsafe"
+	"unicode/utf8"
 
 	"github.com/pboyd04/godata/filter/lexer"
 	"github.com/pboyd04/godata/filter/parser"
 	"github.com/shopspring/decimal"
-
-	"github.com/stretchr/testify/assert"
 )
 
 func init() {
@@ -23,16 +20,30 @@ func init() {
 type Parser struct {
 }
 
-type internalValueState struct {
-	value                interface{}
-	currentComputedValue map[string]interface{}
-	constant             interface{}
-	computedConstant     interface{}
-	isNilConstant        bool
-}
+// ErrorMode controls what happens when a row can't be evaluated, i.e. because a field has the wrong type.
+type ErrorMode int
+
+const (
+	// Strict stops at the first error and returns it.
+	Strict ErrorMode = iota
+	// Lenient treats a row that can't be evaluated as not matching the filter.
+	Lenient
+)
 
 type Evaluator struct {
-	op *parser.Operation
+	op   *parser.Operation
+	fn   compiledFn
+	mode ErrorMode
+}
+
+// Option configures an Evaluator.
+type Option func(*Evaluator)
+
+// WithErrorMode sets the error mode, the default is Strict.
+func WithErrorMode(mode ErrorMode) Option {
+	return func(e *Evaluator) {
+		e.mode = mode
+	}
 }
 
 func (p *Parser) GetDBQuery(common *parser.Parser) (interface{}, error) {
@@ -40,9 +51,7 @@ func (p *Parser) GetDBQuery(common *parser.Parser) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	ret := new(Evaluator)
-	ret.op = op
-	return ret, nil
+	return newEvaluator(op)
 }
 
 func (p *Parser) GetDBQueryWithReplacement(common *parser.Parser, a ...interface{}) (interface{}, error) {
@@ -54,875 +63,553 @@ func (p *Parser) GetDBQueryWithReplacement(common *parser.Parser, a ...interface
 	if err != nil {
 		return nil, err
 	}
-	ret := new(Evaluator)
-	ret.op = op
-	return ret, nil
+	return newEvaluator(op)
 }
 
-func newInternalValueState(data []interface{}) []internalValueState {
-	ret := make([]internalValueState, len(data))
-	for i, val := range data {
-		myInternalState := internalValueState{value: val}
-		myType := reflect.TypeOf(val)
-		myInternalState.currentComputedValue = make(map[string]interface{})
-		if myType.Kind() == reflect.Struct {
-			myFields := reflect.VisibleFields(myType)
-			for _, field := range myFields {
-				name := field.Name
-				if field.Tag.Get("json") != "" {
-					name = field.Tag.Get("json")
-				}
-				myInternalState.currentComputedValue[name] = reflect.ValueOf(val).FieldByIndex(field.Index).Interface()
-			}
-		} else if myType.Kind() == reflect.Map {
-			myMap, ok := val.(map[string]interface{})
+func newEvaluator(op *parser.Operation) (*Evaluator, error) {
+	fn, err := compileOperation(op)
+	if err != nil {
+		return nil, err
+	}
+	return &Evaluator{op: op, fn: fn}, nil
+}
+
+// fieldName returns the name used to refer to a struct field in a filter, the json name if there is one.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// walkPath follows the rest of a property path through nested structs, pointers and maps.
+func walkPath(value interface{}, path *parser.PropertyPath) (interface{}, error) {
+	for _, segment := range path.Segments[1:] {
+		myValue := reflect.ValueOf(value)
+		for myValue.Kind() == reflect.Pointer || myValue.Kind() == reflect.Interface {
+			if myValue.IsNil() {
+				// Anything under a null is also null
+				return nil, nil
+			}
+			myValue = myValue.Elem()
+		}
+		switch myValue.Kind() {
+		case reflect.Struct:
+			fieldValue, ok := accessorFor(myValue.Type()).field(myValue, segment)
 			if !ok {
-				log.Printf("Unknown map type %T\n", val)
-				continue
+				return nil, &UnknownFieldError{field: path.String()}
+			}
+			value = fieldValue
+		case reflect.Map:
+			if myValue.Type().Key().Kind() != reflect.String {
+				return nil, &UnsupportedDataTypeError{}
 			}
-			for k, v := range myMap {
-				myInternalState.currentComputedValue[k] = v
+			// Maps don't have a schema, so a missing key is just null
+			entry := myValue.MapIndex(reflect.ValueOf(segment).Convert(myValue.Type().Key()))
+			if !entry.IsValid() {
+				return nil, nil
 			}
+			value = deref(entry)
+		case reflect.Invalid:
+			return nil, nil
+		default:
+			return nil, &UnknownFieldError{field: path.String()}
 		}
-		ret[i] = myInternalState
 	}
-	return ret
+	return value, nil
 }
 
-type dummyTesting struct{}
-
-func (d dummyTesting) Errorf(_ string, _ ...interface{}) {}
-
-type comparisonFn func(a, b interface{}) bool
-type opFunction func(val interface{}, state *internalValueState, additionalOperands ...*internalValueState) interface{}
+type comparisonFn func(a, b interface{}) (bool, error)
+type opFunction func(val interface{}, additionalOperands ...interface{}) (interface{}, error)
 
 //nolint:gochecknoglobals // This is a map of functions that are used to compare values it's easier to keep it this way than to have a bunch of if statements
 var comparisonMap = map[lexer.TokenKey]comparisonFn{
-	lexer.Equals: func(a, b interface{}) bool {
-		// I don't really get it, but nil is not always nil (i.e. if there is type associated with it, this is basically the only fool proof way that doesn't panic)
-		if (*[2]uintptr)(unsafe.Pointer(&a))[1] == 0 && (*[2]uintptr)(unsafe.Pointer(&b))[1] == 0 {
-			return true
-		}
-		switch aVal := a.(type) {
-		case float64:
-			_, ok := b.(float64)
-			if !ok {
-				bVal, ok := b.(int)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return int(aVal) == bVal
-			}
-			return a == b
-		default:
-			log.Printf("%#v == %#v\n", a, b)
-			return a == b
-		}
+	lexer.Equals: equals,
+	lexer.NotEquals: func(a, b interface{}) (bool, error) {
+		ret, err := equals(a, b)
+		return !ret, err
 	},
-	lexer.NotEquals: func(a, b interface{}) bool {
-		return a != b
+	lexer.GreaterThan: func(a, b interface{}) (bool, error) {
+		return ordered(a, b, func(cmp int) bool { return cmp > 0 })
 	},
-	lexer.GreaterThan: func(a, b interface{}) bool {
-		switch aVal := a.(type) {
-		case int:
-			bVal, ok := b.(int)
-			if !ok {
-				bVal, ok := b.(float64)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return float64(aVal) > bVal
-			}
-			return aVal > bVal
-		case float64:
-			bVal, ok := b.(int)
-			if !ok {
-				bVal, ok := b.(float64)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return aVal > bVal
-			}
-			return aVal > float64(bVal)
-		case string:
-			bVal, ok := b.(string)
-			if !ok {
-				log.Printf("Unknown string compare for second type %T\n", b)
-				return false
-			}
-			return strings.Compare(aVal, bVal) > 0
-		default:
-			log.Printf("Unknown compare for primary type %T\n", a)
-			return false
-		}
+	lexer.GreaterThanOrEqual: func(a, b interface{}) (bool, error) {
+		return ordered(a, b, func(cmp int) bool { return cmp >= 0 })
 	},
-	lexer.GreaterThanOrEqual: func(a, b interface{}) bool {
-		switch aVal := a.(type) {
-		case int:
-			bVal, ok := b.(int)
-			if !ok {
-				bVal, ok := b.(float64)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return float64(aVal) >= bVal
-			}
-			return aVal >= bVal
-		case float64:
-			bVal, ok := b.(int)
-			if !ok {
-				bVal, ok := b.(float64)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return aVal >= bVal
-			}
-			return aVal >= float64(bVal)
-		case string:
-			bVal, ok := b.(string)
-			if !ok {
-				log.Printf("Unknown string compare for second type %T\n", b)
-				return false
-			}
-			return strings.Compare(aVal, bVal) >= 0
-		default:
-			log.Printf("Unknown compare for primary type %T\n", a)
-			return false
-		}
+	lexer.LessThan: func(a, b interface{}) (bool, error) {
+		return ordered(a, b, func(cmp int) bool { return cmp < 0 })
 	},
-	lexer.LessThan: func(a, b interface{}) bool {
-		switch aVal := a.(type) {
-		case int:
-			bVal, ok := b.(int)
-			if !ok {
-				bVal, ok := b.(float64)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return float64(aVal) < bVal
-			}
-			return aVal < bVal
-		case float64:
-			bVal, ok := b.(int)
-			if !ok {
-				bVal, ok := b.(float64)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return aVal < bVal
-			}
-			return aVal < float64(bVal)
-		case string:
-			bVal, ok := b.(string)
-			if !ok {
-				log.Printf("Unknown string compare for second type %T\n", b)
-				return false
-			}
-			return strings.Compare(aVal, bVal) < 0
-		default:
-			log.Printf("Unknown compare for primary type %T\n", a)
-			return false
-		}
+	lexer.LessThanOrEqual: func(a, b interface{}) (bool, error) {
+		return ordered(a, b, func(cmp int) bool { return cmp <= 0 })
 	},
-	lexer.LessThanOrEqual: func(a, b interface{}) bool {
-		switch aVal := a.(type) {
-		case int:
-			bVal, ok := b.(int)
-			if !ok {
-				bVal, ok := b.(float64)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return float64(aVal) <= bVal
-			}
-			return aVal <= bVal
-		case float64:
-			bVal, ok := b.(int)
-			if !ok {
-				bVal, ok := b.(float64)
-				if !ok {
-					log.Printf("Unknown numeric compare for second type %T\n", b)
-					return false
-				}
-				return aVal <= bVal
-			}
-			return aVal <= float64(bVal)
-		case string:
-			bVal, ok := b.(string)
-			if !ok {
-				log.Printf("Unknown string compare for second type %T\n", b)
-				return false
-			}
-			return strings.Compare(aVal, bVal) <= 0
-		default:
-			log.Printf("Unknown compare for primary type %T\n", a)
-			return false
-		}
+	lexer.Contains: func(a, b interface{}) (bool, error) {
+		return stringCompare(a, b, strings.Contains)
 	},
-	lexer.Contains: func(a, b interface{}) bool {
-		switch aVal := a.(type) {
-		case string:
-			bVal, ok := b.(string)
-			if !ok {
-				log.Printf("Unknown string compare for second type %T\n", b)
-			}
-			return strings.Contains(aVal, bVal)
-		default:
-			log.Printf("Unknown contains compare for primary type %T\n", a)
-			return false
-		}
+	lexer.EndsWith: func(a, b interface{}) (bool, error) {
+		return stringCompare(a, b, strings.HasSuffix)
 	},
-	lexer.EndsWith: func(a, b interface{}) bool {
-		switch aVal := a.(type) {
-		case string:
-			bVal, ok := b.(string)
-			if !ok {
-				log.Printf("Unknown string compare for second type %T\n", b)
-			}
-			return strings.HasSuffix(aVal, bVal)
-		default:
-			log.Printf("Unknown endswith compare for primary type %T\n", a)
-			return false
-		}
+	lexer.StartsWith: func(a, b interface{}) (bool, error) {
+		return stringCompare(a, b, strings.HasPrefix)
 	},
-	lexer.StartsWith: func(a, b interface{}) bool {
-		switch aVal := a.(type) {
-		case string:
-			bVal, ok := b.(string)
-			if !ok {
-				log.Printf("Unknown string compare for second type %T\n", b)
-			}
-			return strings.HasPrefix(aVal, bVal)
-		default:
-			log.Printf("Unknown startswith compare for primary type %T\n", a)
-			return false
+	lexer.HasSubset: func(a, b interface{}) (bool, error) {
+		aVal, err := toInterfaceSlice(a)
+		if err != nil || aVal == nil {
+			return false, err
 		}
-	},
-	lexer.HasSubset: func(a, b interface{}) bool {
-		switch bData := b.(type) {
-		case []parser.Operand:
-			bVal := make([]interface{}, 0)
-			for _, val := range bData {
-				valData, err := val.GetData()
-				if err != nil {
-					log.Printf("Error getting data from operand: %v\n", err)
-					return false
-				}
-				bVal = append(bVal, valData)
-			}
-			return assert.Subset(dummyTesting{}, a, bVal)
-		default:
-			return assert.Subset(dummyTesting{}, a, b)
+		bVal, err := toInterfaceSlice(b)
+		if err != nil {
+			return false, err
 		}
+		return subset(aVal, bVal)
 	},
-	lexer.HasSubsequence: func(a, b interface{}) bool {
-		var aVal []interface{}
-		var bVal []interface{}
-		switch val := a.(type) {
-		case []string:
-			aVal = make([]interface{}, len(val))
-			for i, v := range val {
-				aVal[i] = v
-			}
-		case []int:
-			aVal = make([]interface{}, len(val))
-			for i, v := range val {
-				aVal[i] = v
-			}
-		case []float64:
-			aVal = make([]interface{}, len(val))
-			for i, v := range val {
-				aVal[i] = v
-			}
-		default:
-			log.Printf("Unknown has subsequence compare for primary type %T\n", val)
-			return false
+	lexer.HasSubsequence: func(a, b interface{}) (bool, error) {
+		aVal, err := toInterfaceSlice(a)
+		if err != nil || aVal == nil {
+			return false, err
 		}
-		switch val := b.(type) {
-		case []interface{}:
-			bVal = val
-		default:
-			log.Printf("Unknown has subsequence compare for secondary type %T\n", val)
-			return false
+		bVal, err := toInterfaceSlice(b)
+		if err != nil {
+			return false, err
 		}
 		return hasSubsequence(aVal, bVal)
 	},
-	lexer.MatchesPattern: func(a, b interface{}) bool {
-		switch v := a.(type) {
-		case string:
-			reg, err := regexp.Compile(b.(string))
-			if err != nil {
-				log.Printf("Error compiling regex: %v\n", err)
-				return false
-			}
-			return reg.MatchString(v)
-		default:
-			log.Printf("Unknown matches pattern for primary type %T %#v\n", v, b)
-			return false
+	lexer.MatchesPattern: func(a, b interface{}) (bool, error) {
+		pattern, ok := b.(string)
+		if !ok {
+			return false, newTypeMismatchError(a, b)
+		}
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, err
 		}
+		return matchesPattern(reg, a)
 	},
 }
 
-func hasSubsequence(a []interface{}, b []interface{}) bool {
-	if len(b) == 0 {
+// isNil is true for nil and typed nils such as a nil pointer stored in an interface.
+func isNil(value interface{}) bool {
+	if value == nil {
 		return true
 	}
-	if len(b) > len(a) {
+	myValue := reflect.ValueOf(value)
+	switch myValue.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return myValue.IsNil()
+	default:
 		return false
 	}
-	for i := 0; i < len(a); i++ {
-		if a[i] == b[0] {
-			if len(b) == 1 {
-				return true
-			}
-			if i+1 < len(a) {
-				if hasSubsequence(a[i+1:], b[1:]) {
-					return true
-				}
-			}
+}
+
+func equals(a, b interface{}) (bool, error) {
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b), nil
+	}
+	if isNumber(a) || isNumber(b) {
+		cmp, err := compare(a, b)
+		return cmp == 0, err
+	}
+	if aVal, ok := a.(time.Time); ok {
+		bVal, ok := b.(time.Time)
+		if !ok {
+			return false, newTypeMismatchError(a, b)
 		}
+		return aVal.Equal(bVal), nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false, newTypeMismatchError(a, b)
 	}
-	return false
+	if !reflect.TypeOf(a).Comparable() {
+		return reflect.DeepEqual(a, b), nil
+	}
+	return a == b, nil
 }
 
-//nolint:gochecknoglobals // This is a map of functions that are used to operate on values it's easier to keep it this way than to have a bunch of if statements
-var opMap = map[lexer.TokenKey]opFunction{
-	lexer.Length: func(val interface{}, _ *internalValueState, _ ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case string:
-			return len(v)
-		default:
-			log.Printf("Unknown length compare for primary type %T %v\n", val, val)
-			return 0
+// ordered compares two values, null is equal to null and comparing anything else to null is false.
+func ordered(a, b interface{}, test func(int) bool) (bool, error) {
+	if isNil(a) && isNil(b) {
+		return test(0), nil
+	}
+	if isNil(a) || isNil(b) {
+		return false, nil
+	}
+	cmp, err := compare(a, b)
+	if err != nil {
+		return false, err
+	}
+	return test(cmp), nil
+}
+
+func compare(a, b interface{}) (int, error) {
+	switch aVal := a.(type) {
+	case string:
+		bVal, ok := b.(string)
+		if !ok {
+			return 0, newTypeMismatchError(a, b)
 		}
-	},
-	lexer.Add: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("Add requires at least one operand\n")
-			return 0
+		return strings.Compare(aVal, bVal), nil
+	case time.Time:
+		bVal, ok := b.(time.Time)
+		if !ok {
+			return 0, newTypeMismatchError(a, b)
 		}
-		switch v := val.(type) {
-		case int:
-			if additionalOperands[0].IsFloat() {
-				return decimal.NewFromInt(int64(v)).Add(additionalOperands[0].Float())
-			}
-			return v + additionalOperands[0].Int()
-		case float64:
-			ret, _ := decimal.NewFromFloat(v).Add(additionalOperands[0].Float()).Float64()
-			return ret
+		return aVal.Compare(bVal), nil
+	}
+	if !isNumber(a) || !isNumber(b) {
+		return 0, newTypeMismatchError(a, b)
+	}
+	aInt, aIsInt := toInt64(a)
+	bInt, bIsInt := toInt64(b)
+	if aIsInt && bIsInt {
+		switch {
+		case aInt < bInt:
+			return -1, nil
+		case aInt > bInt:
+			return 1, nil
 		default:
-			log.Printf("Unknown add compare for primary type %T %#v\n", v, additionalOperands)
-			return 0
+			return 0, nil
 		}
-	},
-	lexer.Subtract: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("Subtract requires at least one operand\n")
-			return 0
-		}
-		switch v := val.(type) {
-		case int:
-			if additionalOperands[0].IsFloat() {
-				return decimal.NewFromInt(int64(v)).Sub(additionalOperands[0].Float())
-			}
-			return v - additionalOperands[0].Int()
-		case float64:
-			ret, _ := decimal.NewFromFloat(v).Sub(additionalOperands[0].Float()).Float64()
-			return ret
-		default:
-			log.Printf("Unknown add compare for primary type %T %#v\n", v, additionalOperands)
-			return 0
+	}
+	aFloat, bFloat := toFloat64(a), toFloat64(b)
+	switch {
+	case aFloat < bFloat:
+		return -1, nil
+	case aFloat > bFloat:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+func stringCompare(a, b interface{}, test func(string, string) bool) (bool, error) {
+	if isNil(a) || isNil(b) {
+		return false, nil
+	}
+	aVal, aOk := a.(string)
+	bVal, bOk := b.(string)
+	if !aOk || !bOk {
+		return false, newTypeMismatchError(a, b)
+	}
+	return test(aVal, bVal), nil
+}
+
+func matchesPattern(reg *regexp.Regexp, value interface{}) (bool, error) {
+	if isNil(value) {
+		return false, nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return false, newUnsupportedOperandTypeError(value)
+	}
+	return reg.MatchString(str), nil
+}
+
+// toInterfaceSlice converts any slice or array to []interface{}, null is returned as nil.
+func toInterfaceSlice(value interface{}) ([]interface{}, error) {
+	if isNil(value) {
+		return nil, nil
+	}
+	ret, ok := value.([]interface{})
+	if ok {
+		return ret, nil
+	}
+	myValue := reflect.ValueOf(value)
+	if myValue.Kind() != reflect.Slice && myValue.Kind() != reflect.Array {
+		return nil, newUnsupportedOperandTypeError(value)
+	}
+	ret = make([]interface{}, myValue.Len())
+	for i := range ret {
+		ret[i] = myValue.Index(i).Interface()
+	}
+	return ret, nil
+}
+
+// subset reports whether every element of b is in a.
+func subset(a []interface{}, b []interface{}) (bool, error) {
+	for _, value := range b {
+		ok, err := contains(a, value)
+		if err != nil || !ok {
+			return false, err
 		}
-	},
-	lexer.Multiply: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("Multiply requires at least one operand\n")
-			return 0
+	}
+	return true, nil
+}
+
+func hasSubsequence(a []interface{}, b []interface{}) (bool, error) {
+	if len(b) == 0 {
+		return true, nil
+	}
+	if len(b) > len(a) {
+		return false, nil
+	}
+	for i := 0; i < len(a); i++ {
+		same, err := equals(a[i], b[0])
+		if err != nil {
+			return false, err
 		}
-		switch v := val.(type) {
-		case int:
-			if additionalOperands[0].IsFloat() {
-				return decimal.NewFromInt(int64(v)).Mul(additionalOperands[0].Float())
-			}
-			return v * additionalOperands[0].Int()
-		case float64:
-			ret, _ := decimal.NewFromFloat(v).Mul(additionalOperands[0].Float()).Float64()
-			return ret
-		default:
-			log.Printf("Unknown add compare for primary type %T %#v\n", v, additionalOperands)
-			return 0
+		if !same {
+			continue
 		}
-	},
-	lexer.Divide: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("Divide requires at least one operand\n")
-			return 0
+		if len(b) == 1 {
+			return true, nil
 		}
-		switch v := val.(type) {
-		case int:
-			if additionalOperands[0].IsFloat() {
-				ret, _ := decimal.NewFromInt(int64(v)).Div(additionalOperands[0].Float()).Float64()
-				return ret
+		if i+1 < len(a) {
+			ok, err := hasSubsequence(a[i+1:], b[1:])
+			if err != nil || ok {
+				return ok, err
 			}
-			return v / additionalOperands[0].Int()
-		case float64:
-			ret, _ := decimal.NewFromFloat(v).Div(additionalOperands[0].Float()).Float64()
-			return ret
-		default:
-			log.Printf("Unknown add compare for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
-	},
-	lexer.DivideFloat: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("Divide requires at least one operand\n")
-			return 0
 		}
+	}
+	return false, nil
+}
+
+//nolint:gochecknoglobals // This is a map of functions that are used to operate on values it's easier to keep it this way than to have a bunch of if statements
+var opMap = map[lexer.TokenKey]opFunction{
+	lexer.Length: func(val interface{}, _ ...interface{}) (interface{}, error) {
 		switch v := val.(type) {
-		case int:
-			ret, _ := decimal.NewFromInt(int64(v)).Div(additionalOperands[0].Float()).Float64()
-			return ret
-		case float64:
-			ret, _ := decimal.NewFromFloat(v).Div(additionalOperands[0].Float()).Float64()
-			return ret
+		case string:
+			return utf8.RuneCountInString(v), nil
 		default:
-			log.Printf("Unknown add compare for primary type %T %#v\n", v, additionalOperands)
-			return 0
+			list, err := toInterfaceSlice(val)
+			if err != nil || list == nil {
+				return nil, err
+			}
+			return len(list), nil
 		}
 	},
-	lexer.Modulo: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("Divide requires at least one operand\n")
-			return 0
-		}
-		switch v := val.(type) {
-		case int:
-			return v % additionalOperands[0].Int()
-		default:
-			log.Printf("Unknown add compare for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Add: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		return arithmetic(val, additionalOperands, func(a, b int) int { return a + b }, decimal.Decimal.Add)
 	},
-	lexer.Concat: func(val interface{}, state *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("Concat requires at least one operand\n")
-			return 0
-		}
-		switch v := val.(type) {
-		case string:
-			ret := v + additionalOperands[0].String(state)
-			return ret
-		default:
-			log.Printf("Unknown concat for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Subtract: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		return arithmetic(val, additionalOperands, func(a, b int) int { return a - b }, decimal.Decimal.Sub)
+	},
+	lexer.Multiply: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		return arithmetic(val, additionalOperands, func(a, b int) int { return a * b }, decimal.Decimal.Mul)
 	},
-	lexer.IndexOf: func(val interface{}, state *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("IndexOf requires at least one operand\n")
-			return 0
+	lexer.Divide: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		if len(additionalOperands) == 1 && isZero(additionalOperands[0]) {
+			return nil, ErrDivisionByZero
 		}
-		switch v := val.(type) {
-		case string:
-			ret := strings.Index(v, additionalOperands[0].String(state))
-			return ret
-		default:
-			log.Printf("Unknown indexof for primary type %T %#v\n", v, additionalOperands)
-			return 0
+		return arithmetic(val, additionalOperands, func(a, b int) int { return a / b }, decimal.Decimal.Div)
+	},
+	lexer.DivideFloat: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		if len(additionalOperands) == 1 && isZero(additionalOperands[0]) {
+			return nil, ErrDivisionByZero
 		}
+		// divby is always a floating point division
+		return arithmetic(val, additionalOperands, nil, decimal.Decimal.Div)
 	},
-	lexer.Substring: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		if len(additionalOperands) < 1 {
-			log.Printf("Substring requires at least one operand\n")
-			return 0
+	lexer.Modulo: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		if len(additionalOperands) == 1 && isZero(additionalOperands[0]) {
+			return nil, ErrDivisionByZero
 		}
-		switch v := val.(type) {
-		case string:
-			if len(additionalOperands) == 1 {
-				ret := v[additionalOperands[0].Int():]
-				return ret
+		return arithmetic(val, additionalOperands, func(a, b int) int { return a % b }, decimal.Decimal.Mod)
+	},
+	lexer.Concat: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		return stringFunction(val, additionalOperands, 1, func(s string, args []interface{}) (interface{}, error) {
+			other, ok := args[0].(string)
+			if !ok {
+				return nil, newTypeMismatchError(s, args[0])
 			}
-			start := additionalOperands[0].Int()
-			end := additionalOperands[1].Int()
-			end = start + end
-			length := len(v)
-			if end > length {
-				end = length - 1
+			return s + other, nil
+		})
+	},
+	lexer.IndexOf: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		return stringFunction(val, additionalOperands, 1, func(s string, args []interface{}) (interface{}, error) {
+			other, ok := args[0].(string)
+			if !ok {
+				return nil, newTypeMismatchError(s, args[0])
 			}
-			ret := v[start:end]
-			return ret
-		default:
-			log.Printf("Unknown substring for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+			index := strings.Index(s, other)
+			if index < 0 {
+				return index, nil
+			}
+			// OData counts characters, not bytes
+			return utf8.RuneCountInString(s[:index]), nil
+		})
 	},
-	lexer.ToLower: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case string:
-			return strings.ToLower(v)
-		default:
-			log.Printf("Unknown tolower for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Substring: func(val interface{}, additionalOperands ...interface{}) (interface{}, error) {
+		return stringFunction(val, additionalOperands, 1, substring)
 	},
-	lexer.ToUpper: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case string:
-			return strings.ToUpper(v)
-		default:
-			log.Printf("Unknown toupper for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.ToLower: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return stringFunction(val, nil, 0, func(s string, _ []interface{}) (interface{}, error) {
+			return strings.ToLower(s), nil
+		})
 	},
-	lexer.Trim: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case string:
-			return strings.TrimSpace(v)
-		default:
-			log.Printf("Unknown trim for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.ToUpper: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return stringFunction(val, nil, 0, func(s string, _ []interface{}) (interface{}, error) {
+			return strings.ToUpper(s), nil
+		})
 	},
-	lexer.Day: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case time.Time:
-			return v.Day()
-		default:
-			log.Printf("Unknown day for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Trim: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return stringFunction(val, nil, 0, func(s string, _ []interface{}) (interface{}, error) {
+			return strings.TrimSpace(s), nil
+		})
 	},
-	lexer.FractionalSeconds: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case time.Time:
-			ret := float64(v.Nanosecond()) / float64(1000000)
-			return ret
-		default:
-			log.Printf("Unknown fractional seconds for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Day: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return dateFunction(val, func(t time.Time) interface{} { return t.Day() })
 	},
-	lexer.Hour: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case time.Time:
-			return v.Hour()
-		default:
-			log.Printf("Unknown hour for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.FractionalSeconds: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return dateFunction(val, func(t time.Time) interface{} { return float64(t.Nanosecond()) / float64(1000000) })
 	},
-	lexer.Minute: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case time.Time:
-			return v.Minute()
-		default:
-			log.Printf("Unknown minute for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Hour: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return dateFunction(val, func(t time.Time) interface{} { return t.Hour() })
 	},
-	lexer.Month: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case time.Time:
-			return int(v.Month())
-		default:
-			log.Printf("Unknown month for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Minute: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return dateFunction(val, func(t time.Time) interface{} { return t.Minute() })
 	},
-	lexer.Second: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case time.Time:
-			return v.Second()
-		default:
-			log.Printf("Unknown second for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Month: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return dateFunction(val, func(t time.Time) interface{} { return int(t.Month()) })
 	},
-	lexer.Year: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case time.Time:
-			return v.Year()
-		default:
-			log.Printf("Unknown year for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Second: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return dateFunction(val, func(t time.Time) interface{} { return t.Second() })
 	},
-	lexer.Ceiling: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case float64:
-			ret, _ := decimal.NewFromFloat(v).Ceil().Float64()
-			return ret
-		default:
-			log.Printf("Unknown ceiling for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Year: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return dateFunction(val, func(t time.Time) interface{} { return t.Year() })
 	},
-	lexer.Floor: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case float64:
-			ret, _ := decimal.NewFromFloat(v).Floor().Float64()
-			return ret
-		default:
-			log.Printf("Unknown floor for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Ceiling: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return rounding(val, decimal.Decimal.Ceil)
 	},
-	lexer.Round: func(val interface{}, _ *internalValueState, additionalOperands ...*internalValueState) interface{} {
-		switch v := val.(type) {
-		case float64:
-			ret, _ := decimal.NewFromFloat(v).Round(0).Float64()
-			return ret
-		default:
-			log.Printf("Unknown round for primary type %T %#v\n", v, additionalOperands)
-			return 0
-		}
+	lexer.Floor: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return rounding(val, decimal.Decimal.Floor)
+	},
+	lexer.Round: func(val interface{}, _ ...interface{}) (interface{}, error) {
+		return rounding(val, func(d decimal.Decimal) decimal.Decimal { return d.Round(0) })
 	},
 }
 
-func (e *Evaluator) FilterSlice(data []interface{}) ([]interface{}, error) {
-	if len(data) == 0 {
-		return data, nil
+// arithmetic applies a binary operator, ints stay ints unless intFn is nil, anything involving a float is done in decimal.
+func arithmetic(val interface{}, additionalOperands []interface{}, intFn func(int, int) int, decimalFn func(decimal.Decimal, decimal.Decimal) decimal.Decimal) (interface{}, error) {
+	if len(additionalOperands) != 1 {
+		return nil, newParserError("arithmetic operators require exactly two operands")
 	}
-	state := newInternalValueState(data)
-	return e.filterSlice(state, e.op)
-}
-
-func (e *Evaluator) filterSlice(data []internalValueState, op *parser.Operation) ([]interface{}, error) {
-	ret := make([]interface{}, 0)
-	for _, d := range data {
-		ok, err := d.passesOp(op)
-		if err != nil {
-			return nil, err
-		}
-		if ok {
-			ret = append(ret, d.value)
-		}
+	val, other := normalizeNumber(val), normalizeNumber(additionalOperands[0])
+	if isNil(val) || isNil(other) {
+		return nil, nil
 	}
-	return ret, nil
-}
-
-//nolint:cyclop // This function is complex because it has to handle all the different types of operations
-func (d *internalValueState) passesOp(op *parser.Operation) (bool, error) {
-	operands, err := d.getStatesFromOperands(op.Operands)
-	if err != nil {
-		return false, err
+	if !isNumber(val) || !isNumber(other) {
+		return nil, newTypeMismatchError(val, other)
 	}
-	switch op.Operator {
-	case lexer.TokenTrue:
-		return true, nil
-	case lexer.TokenFalse:
-		return false, nil
-	case lexer.Equals, lexer.NotEquals, lexer.GreaterThan, lexer.GreaterThanOrEqual, lexer.LessThan, lexer.LessThanOrEqual, lexer.Contains, lexer.EndsWith, lexer.StartsWith, lexer.HasSubset, lexer.HasSubsequence, lexer.MatchesPattern:
-		return d.simpleCompare(operands[0], operands[1], comparisonMap[lexer.TokenKey(op.Operator)])
-	case lexer.And:
-		if operands[0] == nil || operands[1] == nil {
-			return false, nil
-		}
-		return true, nil
-	case lexer.Or:
-		if operands[0] == nil && operands[1] == nil {
-			return false, nil
-		}
-		return true, nil
-	case lexer.In:
-		return d.in(operands[0], operands[1])
-	case lexer.Not:
-		return operands[0] == nil, nil
-	case lexer.Length, lexer.Add, lexer.Subtract, lexer.Multiply, lexer.Divide, lexer.DivideFloat, lexer.Modulo, lexer.Concat, lexer.IndexOf, lexer.Substring, lexer.ToLower, lexer.ToUpper, lexer.Trim, lexer.Day, lexer.FractionalSeconds, lexer.Hour, lexer.Minute, lexer.Month, lexer.Second, lexer.Year, lexer.Ceiling, lexer.Floor, lexer.Round:
-		return d.computeOperation(operands, lexer.TokenKey(op.Operator))
-	case parser.NoOp:
-		return true, nil
-	default:
-		return false, &UnsupportedOperatorError{operator: lexer.TokenKey(op.Operator)}
+	aInt, aIsInt := val.(int)
+	bInt, bIsInt := other.(int)
+	if intFn != nil && aIsInt && bIsInt {
+		return intFn(aInt, bInt), nil
 	}
+	ret, _ := decimalFn(toDecimal(val), toDecimal(other)).Float64()
+	return ret, nil
 }
 
-func (d *internalValueState) IsFloat() bool {
-	_, ok := d.computedConstant.(float64)
-	if !ok && d.constant != nil {
-		_, ok = d.constant.(float64)
-	}
-	return ok
+func isZero(value interface{}) bool {
+	return isNumber(value) && toDecimal(value).IsZero()
 }
 
-func (d *internalValueState) Float() decimal.Decimal {
-	value := d.computedConstant
-	if value == nil {
-		value = d.constant
+func stringFunction(val interface{}, args []interface{}, minArgs int, fn func(string, []interface{}) (interface{}, error)) (interface{}, error) {
+	if len(args) < minArgs {
+		return nil, newParserError("not enough operands")
 	}
-	switch val := value.(type) {
-	case float64:
-		return decimal.NewFromFloat(val)
-	case int:
-		return decimal.NewFromInt(int64(val))
-	default:
-		log.Printf("Unknown float type %T\n", val)
-		return decimal.NewFromInt(0)
+	if isNil(val) {
+		return nil, nil
 	}
-}
-
-func (d *internalValueState) Int() int {
-	value := d.computedConstant
-	if value == nil {
-		value = d.constant
+	for _, arg := range args {
+		if isNil(arg) {
+			return nil, nil
+		}
 	}
-	switch val := value.(type) {
-	case float64:
-		return int(val)
-	case int:
-		return val
-	default:
-		log.Printf("Unknown int type %T\n", val)
-		return 0
+	str, ok := val.(string)
+	if !ok {
+		return nil, newUnsupportedOperandTypeError(val)
 	}
+	return fn(str, args)
 }
 
-func (d *internalValueState) String(state *internalValueState) string {
-	value := d.computedConstant
-	if value == nil {
-		value = d.constant
-	}
-	strValue, ok := value.(string)
-	if ok {
-		fieldValue, ok := state.currentComputedValue[strValue]
-		if ok {
-			value = fieldValue
+func substring(s string, args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if _, ok := arg.(int); !ok {
+			return nil, newTypeMismatchError(s, arg)
 		}
 	}
-	switch val := value.(type) {
-	case string:
-		return val
-	default:
-		log.Printf("Unknown string type %T\n", val)
-		return ""
+	runes := []rune(s)
+	//nolint:forcetypeassert // Checked above
+	start := clamp(args[0].(int), 0, len(runes))
+	if len(args) == 1 {
+		return string(runes[start:]), nil
 	}
+	//nolint:forcetypeassert // Checked above
+	end := clamp(start+args[1].(int), start, len(runes))
+	return string(runes[start:end]), nil
 }
 
-func (d *internalValueState) simpleCompare(op1 *internalValueState, op2 *internalValueState, compareFn comparisonFn) (bool, error) {
-	var value interface{}
-	var value2 interface{}
-	if op1.computedConstant != nil {
-		value = op1.computedConstant
-	} else {
-		strVal, ok := op1.constant.(string)
-		if !ok {
-			return false, &UnsupportedDataTypeError{}
-		}
-		fieldValue, ok := d.currentComputedValue[strVal]
-		if !ok {
-			return false, &UnknownFieldError{field: strVal}
-		}
-		value = fieldValue
+func clamp(value, low, high int) int {
+	if value < low {
+		return low
 	}
-	switch {
-	case op2.isNilConstant:
-		value2 = nil
-	case op2.computedConstant != nil:
-		value2 = op2.computedConstant
-	default:
-		value2 = op2.constant
-		strValue2, ok := op2.constant.(string)
-		if ok {
-			fieldValue, ok := d.currentComputedValue[strValue2]
-			if ok {
-				value2 = fieldValue
-			}
-		}
+	if value > high {
+		return high
 	}
-	return compareFn(value, value2), nil
+	return value
 }
 
-func (d *internalValueState) in(op1 *internalValueState, op2 *internalValueState) (bool, error) {
-	strVal, ok := op1.constant.(string)
-	if !ok {
-		return false, &UnsupportedDataTypeError{}
+func dateFunction(val interface{}, fn func(time.Time) interface{}) (interface{}, error) {
+	if isNil(val) {
+		return nil, nil
 	}
-	fieldValue, ok := d.currentComputedValue[strVal]
+	t, ok := val.(time.Time)
 	if !ok {
-		return false, &UnknownFieldError{field: strVal}
+		return nil, newUnsupportedOperandTypeError(val)
 	}
-	return assert.Contains(dummyTesting{}, op2.constant, fieldValue), nil
+	return fn(t), nil
 }
 
-func (d *internalValueState) computeOperation(operands []*internalValueState, op lexer.TokenKey) (bool, error) {
-	if len(operands) < 1 {
-		return false, newParserError("computed operations require at least one operand")
-	}
-	var value interface{}
-	if operands[0].computedConstant != nil {
-		value = operands[0].computedConstant
-	} else {
-		strVal, ok := operands[0].constant.(string)
-		if !ok {
-			return false, &UnsupportedDataTypeError{}
-		}
-		fieldValue, ok := d.currentComputedValue[strVal]
-		if !ok {
-			return false, &UnknownFieldError{field: strVal}
-		}
-		value = fieldValue
-	}
-	opMapFn, ok := opMap[op]
-	if !ok {
-		return false, &UnsupportedOperatorError{operator: op}
+func rounding(val interface{}, fn func(decimal.Decimal) decimal.Decimal) (interface{}, error) {
+	switch v := normalizeNumber(val).(type) {
+	case nil:
+		return nil, nil
+	case int:
+		return v, nil
+	case float64:
+		ret, _ := fn(decimal.NewFromFloat(v)).Float64()
+		return ret, nil
+	default:
+		return nil, newUnsupportedOperandTypeError(val)
 	}
-	d.computedConstant = opMapFn(value, d, operands[1:]...)
-	return true, nil
 }
 
-func (d *internalValueState) getStatesFromOperands(operands []parser.Operand) ([]*internalValueState, error) {
-	ret := make([]*internalValueState, len(operands))
-	for i, operand := range operands {
-		tmp, err := d.getStateFromOperand(operand)
+func (e *Evaluator) FilterSlice(data []interface{}) ([]interface{}, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	ret := make([]interface{}, 0)
+	r := new(row)
+	for _, item := range data {
+		r.reset(item)
+		ok, err := e.fn(r)
 		if err != nil {
+			if e.mode == Lenient {
+				continue
+			}
 			return nil, err
 		}
-		ret[i] = tmp
+		if truthy(ok) {
+			ret = append(ret, item)
+		}
 	}
 	return ret, nil
 }
 
-//nolint:cyclop // This function isn't that complex
-func (d *internalValueState) getStateFromOperand(operand parser.Operand) (*internalValueState, error) {
-	data, err := operand.GetData()
+// SetErrorMode changes how rows that can't be evaluated are handled.
+func (e *Evaluator) SetErrorMode(mode ErrorMode) {
+	e.mode = mode
+}
+
+// Matches returns true if a single struct or map passes the filter.
+func (e *Evaluator) Matches(item interface{}) (bool, error) {
+	r := new(row)
+	r.reset(item)
+	ok, err := e.fn(r)
 	if err != nil {
-		return nil, err
-	}
-	switch op := data.(type) {
-	case string, float64, int:
-		return &internalValueState{constant: op}, nil
-	case *parser.Operation:
-		passes, err := d.passesOp(op)
-		if err != nil {
-			return nil, err
-		}
-		if !passes {
-			return nil, nil
-		}
-		return d, nil
-	case []parser.Operand:
-		ret := new(internalValueState)
-		ret.constant = make([]interface{}, 0)
-		for _, o := range op {
-			inner, err := d.getStateFromOperand(o)
-			if err != nil {
-				return nil, err
-			}
-			//nolint:forcetypeassert // The variable was assigned just above
-			ret.constant = append(ret.constant.([]interface{}), inner.constant)
+		if e.mode == Lenient {
+			return false, nil
 		}
-		return ret, nil
-	case nil:
-		return &internalValueState{isNilConstant: true}, nil
-	default:
-		return nil, newUnsupportedOperandError(op)
+		return false, err
 	}
+	return truthy(ok), nil
 }
@@ -4,8 +4,12 @@ import (
 	"testing"
 	"time"
 
+	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/filter"
+	"github.com/pboyd04/godata/filter/lexer"
 	"github.com/pboyd04/godata/filter/parser"
 	"github.com/pboyd04/godata/filter/parser/golang"
+	"github.com/pboyd04/godata/orderby"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -22,6 +26,7 @@ type testInputStruct struct {
 	IntArray  []int
 	Date      time.Time
 	TestPtr   *testInputStruct
+	Meta      map[string]interface{}
 }
 
 //nolint:gochecknoglobals // Just test data
@@ -33,7 +38,7 @@ var testInputData = []interface{}{
 		Array:     []string{"1", "2", "3", "5"},
 		IntArray:  []int{1, 2, 3, 5},
 		Date:      time.Date(2022, 9, 8, 4, 0, 0, 0, time.UTC),
-		TestPtr:   new(testInputStruct),
+		TestPtr:   &testInputStruct{Name: "nested", L: "Paris"},
 	},
 	testInputStruct{
 		Name:     "bob ",
@@ -56,6 +61,7 @@ var testInputData = []interface{}{
 		Array:   []string{"Milk", "Bob"},
 		L:       "Berlin",
 		C:       "United States",
+		Meta:    map[string]interface{}{"Color": "red", "Size": map[string]interface{}{"Width": 3}},
 	},
 	testInputStruct{
 		Name:    "Cheese",
@@ -269,6 +275,38 @@ var testCases = []testData{
 		input:          `round(Price) eq 3`,
 		expectedOutput: []interface{}{testInputData[1], testInputData[2]},
 	},
+	{
+		input:          `case(Name eq 'Milk':1, Name eq 'Cheese':2, true:0) gt 0`,
+		expectedOutput: []interface{}{testInputData[2], testInputData[3], testInputData[4]},
+	},
+	{
+		input:          `case(Int gt 3:Price mul 2, true:Price) gt 5`,
+		expectedOutput: []interface{}{testInputData[4]},
+	},
+	{
+		input:          `case(Name eq 'Milk':1) eq 1`,
+		expectedOutput: []interface{}{testInputData[2], testInputData[3]},
+	},
+	{
+		input:          `TestPtr/Name eq 'nested'`,
+		expectedOutput: []interface{}{testInputData[0]},
+	},
+	{
+		input:          `TestPtr/City eq 'Paris' and TestPtr/TestPtr eq null`,
+		expectedOutput: []interface{}{testInputData[0]},
+	},
+	{
+		input:          `Meta/Color eq 'red'`,
+		expectedOutput: []interface{}{testInputData[3]},
+	},
+	{
+		input:          `Meta/Size/Width gt 2`,
+		expectedOutput: []interface{}{testInputData[3]},
+	},
+	{
+		input:          `length(TestPtr/Name) eq 6`,
+		expectedOutput: []interface{}{testInputData[0]},
+	},
 	{
 		input:          `TestPtr eq null`,
 		expectedOutput: []interface{}{testInputData[1], testInputData[2], testInputData[3], testInputData[4]},
@@ -301,3 +339,344 @@ func TestGoLang(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterTyped(t *testing.T) {
+	t.Parallel()
+	items := make([]testInputStruct, 0, len(testInputData))
+	ptrs := make([]*testInputStruct, 0, len(testInputData))
+	for _, item := range testInputData {
+		//nolint:forcetypeassert // Just test data
+		typed := item.(testInputStruct)
+		items = append(items, typed)
+		ptrs = append(ptrs, &typed)
+	}
+	for _, test := range testCases {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			f := filter.MustCompile(tc.input)
+			res, err := golang.Filter(f, items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := make([]testInputStruct, 0, len(tc.expectedOutput))
+			for _, item := range tc.expectedOutput {
+				//nolint:forcetypeassert // Just test data
+				expected = append(expected, item.(testInputStruct))
+			}
+			assert.ElementsMatch(t, expected, res)
+			ptrRes, err := golang.Filter(f, ptrs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Len(t, ptrRes, len(expected))
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+	matches, err := golang.Compile[testInputStruct](filter.MustCompile("Name eq 'Milk' and Price lt 2.55"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//nolint:forcetypeassert // Just test data
+	ok, err := matches(testInputData[3].(testInputStruct))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ok)
+	//nolint:forcetypeassert // Just test data
+	ok, err = matches(testInputData[2].(testInputStruct))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, ok)
+}
+
+func BenchmarkFilterSlice(b *testing.B) {
+	data := make([]interface{}, 0, 10000)
+	for i := 0; i < 2000; i++ {
+		data = append(data, testInputData...)
+	}
+	for _, input := range []string{
+		"Name eq 'Milk' and Price lt 2.55",
+		"contains(Name,'ee') or Int gt 3",
+		"length(Name) gt 4",
+		"TestPtr/Name eq 'nested'",
+		"Name in ('Milk', 'Cheese')",
+	} {
+		common, err := parser.NewParser(input)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ptr, err := common.GetDBQuery("golang")
+		if err != nil {
+			b.Fatal(err)
+		}
+		op, err := common.GetOperation()
+		if err != nil {
+			b.Fatal(err)
+		}
+		// The interpreter is how filters were evaluated before they were compiled, run both to compare them
+		evaluators := []struct {
+			name string
+			eval interface {
+				FilterSlice(data []interface{}) ([]interface{}, error)
+			}
+		}{
+			//nolint:forcetypeassert // Just test code
+			{"compiled", ptr.(*golang.Evaluator)},
+			{"interpreted", golang.NewInterpreter(op)},
+		}
+		for _, evaluator := range evaluators {
+			eval := evaluator.eval
+			b.Run(evaluator.name+"/"+input, func(b *testing.B) {
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, err := eval.FilterSlice(data)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestFilterSliceMaps(t *testing.T) {
+	t.Parallel()
+	data := []interface{}{
+		map[string]interface{}{"Name": "Milk", "Price": 2.55},
+		map[string]interface{}{"Name": "Cheese"},
+	}
+	common, err := parser.NewParser("Price eq null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptr, err := common.GetDBQuery("golang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//nolint:forcetypeassert // Just test code
+	res, err := ptr.(*golang.Evaluator).FilterSlice(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []interface{}{data[1]}, res)
+}
+
+func TestInNumericKinds(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Qty    int64
+		Small  uint8
+		Counts []int64
+	}
+	items := []item{{Qty: 1, Small: 2, Counts: []int64{1, 2, 3}}, {Qty: 3, Small: 4, Counts: []int64{3}}}
+	for input, expected := range map[string]int{
+		"Qty eq 1":                     1,
+		"Qty in (1, 2)":                1,
+		"Small in (2, 4.0)":            2,
+		"Qty in (1.5, 3)":              1,
+		"hassubset(Counts,[1,2])":      1,
+		"hassubsequence(Counts,[1,3])": 1,
+	} {
+		res, err := golang.Filter(filter.MustCompile(input), items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != expected {
+			t.Errorf("%s: expected %d items, got %v", input, expected, res)
+		}
+	}
+}
+
+func TestNumericKinds(t *testing.T) {
+	t.Parallel()
+	type quantity int32
+	type item struct {
+		F32   float32
+		I16   int16
+		U32   uint32
+		Named quantity
+	}
+	items := []item{{F32: 2.5, I16: 3, U32: 4, Named: 5}}
+	for _, input := range []string{
+		"round(F32) eq 3",
+		"floor(F32) eq 2",
+		"ceiling(I16) eq 3",
+		"F32 add 0.1 eq 2.6",
+		"I16 add 1 eq 4",
+		"I16 div 2 eq 1",
+		"U32 mul 2 eq 8",
+		"Named mod 4 eq 1",
+		"Named gt I16",
+	} {
+		res, err := golang.Filter(filter.MustCompile(input), items)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if len(res) != 1 {
+			t.Errorf("%s: expected a match", input)
+		}
+	}
+}
+
+func TestNonASCIIStrings(t *testing.T) {
+	t.Parallel()
+	type item struct {
+		Name string
+	}
+	items := []item{{Name: "Café Crème"}}
+	for _, input := range []string{
+		"length('é') eq 1",
+		"length(Name) eq 10",
+		"indexof(Name,'Crème') eq 5",
+		"indexof(Name,'x') eq -1",
+		"substring(Name,3) eq 'é Crème'",
+		"substring(Name,5,3) eq 'Crè'",
+		"substring(Name,8,10) eq 'me'",
+	} {
+		res, err := golang.Filter(filter.MustCompile(input), items)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if len(res) != 1 {
+			t.Errorf("%s: expected a match", input)
+		}
+	}
+}
+
+func TestPointerFields(t *testing.T) {
+	t.Parallel()
+	milk, price := "Milk", 2.5
+	type item struct {
+		ID    int
+		Name  *string
+		Price *float64
+	}
+	items := []item{{ID: 1, Name: &milk, Price: &price}, {ID: 2}}
+	for input, expected := range map[string][]int{
+		"Name eq 'Milk'":        {1},
+		"Name ne 'Milk'":        {2},
+		"not (Name eq 'Milk')":  {2},
+		"Name eq null":          {2},
+		"Price gt 2":            {1},
+		"length(Name) eq 4":     {1},
+		"Name in ('Milk', 'x')": {1},
+	} {
+		res, err := golang.Filter(filter.MustCompile(input), items)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		ids := []int{}
+		for _, i := range res {
+			ids = append(ids, i.ID)
+		}
+		assert.Equal(t, expected, ids, input)
+	}
+}
+
+func TestFilterSliceUnknownField(t *testing.T) {
+	t.Parallel()
+	_, err := golang.Filter(filter.MustCompile("Missing eq 1"), []testInputStruct{{Name: "Milk"}})
+	var unknown *golang.UnknownFieldError
+	assert.ErrorAs(t, err, &unknown)
+}
+
+func TestErrorModes(t *testing.T) {
+	t.Parallel()
+	items := []testInputStruct{{Name: "Milk", Float64: 2.55}, {Name: "Cheese", Float64: 10.1}}
+	_, err := golang.Filter(filter.MustCompile("Price eq 'abc'"), items)
+	var mismatch *golang.TypeMismatchError
+	if assert.ErrorAs(t, err, &mismatch) {
+		assert.Equal(t, lexer.TokenKey(lexer.Equals), mismatch.Operator)
+		assert.Equal(t, "Price", mismatch.Field)
+	}
+	_, err = golang.Filter(filter.MustCompile("year(Name) eq 2022"), items)
+	var unsupported *golang.UnsupportedOperandTypeError
+	if assert.ErrorAs(t, err, &unsupported) {
+		assert.Equal(t, lexer.TokenKey(lexer.Year), unsupported.Operator)
+		assert.Equal(t, "Name", unsupported.Field)
+	}
+	_, err = golang.Filter(filter.MustCompile("Price div 0 eq 1"), items)
+	assert.ErrorIs(t, err, golang.ErrDivisionByZero)
+	for _, input := range []string{"not trim(Name)", "Name and Price gt 1", "Price lt 1 or Name"} {
+		_, err = golang.Filter(filter.MustCompile(input), items)
+		if assert.ErrorAs(t, err, &unsupported, input) {
+			assert.Equal(t, "Name", unsupported.Field, input)
+		}
+		res, err := golang.Filter(filter.MustCompile(input), items, golang.WithErrorMode(golang.Lenient))
+		assert.NoError(t, err, input)
+		assert.Empty(t, res, input)
+	}
+	res, err := golang.Filter(filter.MustCompile("Price eq 'abc' or Name eq 'Cheese'"), items, golang.WithErrorMode(golang.Lenient))
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+	res, err = golang.Filter(filter.MustCompile("Name eq 'Cheese' or Price eq 'abc'"), items, golang.WithErrorMode(golang.Lenient))
+	assert.NoError(t, err)
+	assert.Equal(t, items[1:], res)
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+	items := []testInputStruct{
+		{Name: "Milk", Float64: 2.55, Int: 2},
+		{Name: "Cheese", Float64: 10.1, Int: 1},
+		{Name: "Bread", Float64: 2.55, Int: 3},
+		{Name: "Eggs", Float64: 4.2, Int: 4},
+	}
+	opts := odata.NewQueryOptions()
+	assert.NoError(t, opts.AddFilter("Price lt 10"))
+	orderBy, err := orderby.NewOrderBy("Price desc,Name")
+	assert.NoError(t, err)
+	opts.OrderBy = orderBy
+	opts.Skip = 1
+	opts.Top = 1
+	opts.Count = true
+	opts.Select = &[]string{"Name", "Price"}
+	res, err := golang.Apply(opts, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []testInputStruct{items[2]}, res.Items)
+	assert.Equal(t, []map[string]interface{}{{"Name": "Bread", "Price": 2.55}}, res.Selected)
+	if assert.NotNil(t, res.Count) {
+		assert.Equal(t, int64(3), *res.Count)
+	}
+	// The caller's slice is left alone
+	assert.Equal(t, "Milk", items[0].Name)
+
+	res, err = golang.Apply(odata.NewQueryOptions(), items)
+	assert.NoError(t, err)
+	assert.Equal(t, items, res.Items)
+	assert.Nil(t, res.Selected)
+	assert.Nil(t, res.Count)
+
+	opts = odata.NewQueryOptions()
+	opts.AddTop(0)
+	opts.Count = true
+	res, err = golang.Apply(opts, items)
+	assert.NoError(t, err)
+	assert.Empty(t, res.Items)
+	if assert.NotNil(t, res.Count) {
+		assert.Equal(t, int64(4), *res.Count)
+	}
+
+	opts = odata.NewQueryOptions()
+	opts.Select = &[]string{"Missing"}
+	_, err = golang.Apply(opts, items)
+	var unknown *golang.UnknownFieldError
+	assert.ErrorAs(t, err, &unknown)
+
+	maps := []map[string]interface{}{{"Name": "Milk", "Price": 2.55}, {"Name": "Cheese"}, {"Name": "Eggs", "Price": 4.2}}
+	opts = odata.NewQueryOptions()
+	orderBy, err = orderby.NewOrderBy("Price")
+	assert.NoError(t, err)
+	opts.OrderBy = orderBy
+	mapRes, err := golang.Apply(opts, maps)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{maps[1], maps[0], maps[2]}, mapRes.Items)
+}
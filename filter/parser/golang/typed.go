@@ -0,0 +1,52 @@
+package golang
+
+import (
+	"fmt"
+
+	"github.com/pboyd04/godata/filter"
+)
+
+// Compile turns a filter into a function that tests a single item.
+func Compile[T any](f *filter.Filter, opts ...Option) (func(T) (bool, error), error) {
+	evaluator, err := getEvaluator(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(evaluator)
+	}
+	return func(item T) (bool, error) {
+		return evaluator.Matches(item)
+	}, nil
+}
+
+// Filter returns the items that pass the filter, in their original order.
+func Filter[T any](f *filter.Filter, items []T, opts ...Option) ([]T, error) {
+	matches, err := Compile[T](f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]T, 0)
+	for _, item := range items {
+		ok, err := matches(item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ret = append(ret, item)
+		}
+	}
+	return ret, nil
+}
+
+func getEvaluator(f *filter.Filter) (*Evaluator, error) {
+	query, err := f.GetDBQuery("golang")
+	if err != nil {
+		return nil, err
+	}
+	evaluator, ok := query.(*Evaluator)
+	if !ok {
+		return nil, newParserError(fmt.Sprintf("expected an Evaluator, got %T", query))
+	}
+	return evaluator, nil
+}
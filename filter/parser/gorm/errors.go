@@ -3,6 +3,8 @@ package gorm
 import (
 	"fmt"
 
+	"github.com/pboyd04/godata/errcode"
+	"github.com/pboyd04/godata/filter/lexer"
 	"github.com/pboyd04/godata/filter/parser"
 )
 
@@ -37,3 +39,23 @@ func newUnsupportedOperatorError(operator parser.Operator) error {
 func (e *ParserError) Error() string {
 	return e.message
 }
+
+func newParserError(message string) error {
+	return &ParserError{message: message}
+}
+
+func newOperandCountError(operator parser.Operator, count int) error {
+	return newParserError(fmt.Sprintf("%s requires %d operands", lexer.TokenKey(operator), count))
+}
+
+func (e *UnsupportedOperandError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
+
+func (e *UnsupportedOperatorError) ErrorCode() errcode.Code {
+	return errcode.UnsupportedFunction
+}
+
+func (e *ParserError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
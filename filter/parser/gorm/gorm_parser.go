@@ -1,15 +1,30 @@
 package gorm
 
 import (
+	"encoding/json"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pboyd04/godata/filter/lexer"
 	"github.com/pboyd04/godata/filter/parser"
+	"gorm.io/gorm/clause"
 )
 
-const likeStr = " LIKE ?"
+// PathStyle controls how property paths such as Address/City are turned into SQL.
+type PathStyle int
+
+const (
+	// JoinAlias treats everything but the last segment as the alias of a joined table, i.e. Address.City.
+	JoinAlias PathStyle = iota
+	// JSONPath treats the first segment as a JSON column and the rest as a path inside it, i.e. Address->>'$.City'.
+	// This syntax is understood by MySQL and SQLite.
+	JSONPath
+)
 
 type Parser struct {
+	pathStyle PathStyle
+	aliases   map[string]string
 }
 
 func init() {
@@ -17,6 +32,14 @@ func init() {
 	parser.RegisterParser("gorm", &Parser{})
 }
 
+// NewParser creates a gorm parser with a custom property path style. For JoinAlias the aliases map the navigation
+// part of a path (i.e. Customer/Address) to the alias used in the query, by default the segments are joined with _.
+// Register the result with parser.RegisterParser to use it.
+func NewParser(pathStyle PathStyle, aliases map[string]string) *Parser {
+	return &Parser{pathStyle: pathStyle, aliases: aliases}
+}
+
+// GetDBQuery returns a clause.Expression that can be passed straight to gorm's Where.
 func (p *Parser) GetDBQuery(common *parser.Parser) (interface{}, error) {
 	op, err := common.GetOperation()
 	if err != nil {
@@ -37,79 +60,411 @@ func (p *Parser) GetDBQueryWithReplacement(common *parser.Parser, a ...interface
 	return p.getGormQuery(op)
 }
 
-//nolint:funlen,cyclop,forcetypeassert
-func (p *Parser) getGormQuery(op *parser.Operation) ([]interface{}, error) {
+// Where there's no portable SQL the templates use MySQL's, i.e. matchesPattern is a case sensitive REGEXP_LIKE. div
+// isn't a template, see doDivide.
+//
+//nolint:gochecknoglobals // This is a map of SQL templates it's easier to keep it this way than to have a bunch of if statements
+var functionTemplates = map[parser.Operator]string{
+	lexer.Add:               "(? + ?)",
+	lexer.Subtract:          "(? - ?)",
+	lexer.Multiply:          "(? * ?)",
+	lexer.DivideFloat:       "(? / ?)",
+	lexer.Modulo:            "(? % ?)",
+	lexer.Length:            "CHAR_LENGTH(?)",
+	lexer.ToLower:           "LOWER(?)",
+	lexer.ToUpper:           "UPPER(?)",
+	lexer.Trim:              "TRIM(?)",
+	lexer.Concat:            "CONCAT(?, ?)",
+	lexer.IndexOf:           "(INSTR(?, ?) - 1)",
+	lexer.Year:              "EXTRACT(YEAR FROM ?)",
+	lexer.Month:             "EXTRACT(MONTH FROM ?)",
+	lexer.Day:               "EXTRACT(DAY FROM ?)",
+	lexer.Hour:              "EXTRACT(HOUR FROM ?)",
+	lexer.Minute:            "EXTRACT(MINUTE FROM ?)",
+	lexer.Second:            "EXTRACT(SECOND FROM ?)",
+	lexer.FractionalSeconds: "(EXTRACT(MICROSECOND FROM ?) / 1000)",
+	lexer.Round:             "ROUND(?)",
+	lexer.Floor:             "FLOOR(?)",
+	lexer.Ceiling:           "CEILING(?)",
+	lexer.MatchesPattern:    "REGEXP_LIKE(?, ?, 'c')",
+}
+
+//nolint:cyclop // This is just a big switch statement
+func (p *Parser) getGormQuery(op *parser.Operation) (clause.Expression, error) {
+	//nolint:exhaustive // This won't cover everything and will use the default case to catch errors
+	switch op.Operator {
+	case lexer.TokenTrue:
+		return trueExpr, nil
+	case lexer.TokenFalse:
+		return falseExpr, nil
+	case lexer.Case:
+		return p.doCase(op)
+	}
 	operands, err := p.getGormOperands(op.Operands)
 	if err != nil {
 		return nil, err
 	}
+	if template, ok := functionTemplates[op.Operator]; ok {
+		return doFunction(op.Operator, template, operands)
+	}
+	//nolint:exhaustive // This won't cover everything and will use the default case to catch errors
+	switch op.Operator {
+	case lexer.Substring:
+		return doSubstring(operands)
+	case lexer.Divide:
+		return doDivide(operands)
+	case lexer.Not:
+		if len(operands) != 1 {
+			return nil, newOperandCountError(op.Operator, 1)
+		}
+		return doNot(operands[0], !parser.NullSafeNot(op.Operands[0]))
+	case lexer.And, lexer.Or:
+		return doConjunction(op.Operator, operands)
+	}
+	if len(operands) != 2 {
+		return nil, newOperandCountError(op.Operator, 2)
+	}
+	if test, other := parser.CompareNull(op.Operator, operands); test != parser.NoNullTest {
+		return doNullTest(test, other), nil
+	}
 	//nolint:exhaustive // This won't cover everything and will use the default case to catch errors
 	switch op.Operator {
 	case lexer.Equals:
-		return []interface{}{operands[0].(string) + " = ?", operands[1]}, nil
+		return clause.Eq{Column: toColumn(operands[0]), Value: operands[1]}, nil
 	case lexer.NotEquals:
-		return []interface{}{operands[0].(string) + " != ?", operands[1]}, nil
+		return doNotEquals(operands[0], operands[1]), nil
 	case lexer.GreaterThan:
-		return []interface{}{operands[0].(string) + " > ?", operands[1]}, nil
+		return clause.Gt{Column: toColumn(operands[0]), Value: operands[1]}, nil
 	case lexer.GreaterThanOrEqual:
-		return []interface{}{operands[0].(string) + " >= ?", operands[1]}, nil
+		return clause.Gte{Column: toColumn(operands[0]), Value: operands[1]}, nil
 	case lexer.LessThan:
-		return []interface{}{operands[0].(string) + " < ?", operands[1]}, nil
+		return clause.Lt{Column: toColumn(operands[0]), Value: operands[1]}, nil
 	case lexer.LessThanOrEqual:
-		return []interface{}{operands[0].(string) + " <= ?", operands[1]}, nil
-	case lexer.And:
-		clause1 := operands[0].([]interface{})
-		clause2 := operands[1].([]interface{})
-		ret := []interface{}{clause1[0].(string) + " AND " + clause2[0].(string)}
-		ret = append(ret, clause1[1:]...)
-		ret = append(ret, clause2[1:]...)
-		return ret, nil
-	case lexer.Or:
-		clause1 := operands[0].([]interface{})
-		clause2 := operands[1].([]interface{})
-		ret := []interface{}{clause1[0].(string) + " OR " + clause2[0].(string)}
-		ret = append(ret, clause1[1:]...)
-		ret = append(ret, clause2[1:]...)
-		return ret, nil
+		return clause.Lte{Column: toColumn(operands[0]), Value: operands[1]}, nil
 	case lexer.In:
-		ret := []interface{}{operands[0].(string) + " IN ?"}
-		ret = append(ret, operands[1:]...)
-		return ret, nil
+		return doIn(operands[0], operands[1]), nil
 	case lexer.Contains:
-		return []interface{}{operands[0].(string) + likeStr, "%" + operands[1].(string) + "%"}, nil
+		return doLike("%", "%", operands[0], operands[1])
 	case lexer.EndsWith:
-		return []interface{}{operands[0].(string) + likeStr, "%" + operands[1].(string)}, nil
+		return doLike("%", "", operands[0], operands[1])
 	case lexer.StartsWith:
-		return []interface{}{operands[0].(string) + likeStr, operands[1].(string) + "%"}, nil
-	case lexer.Not:
-		return insertNotOp(operands[0])
+		return doLike("", "%", operands[0], operands[1])
+	case lexer.HasSubset:
+		return doHasSubset(operands[0], operands[1])
+	case lexer.HasSubsequence:
+		return doHasSubsequence(operands[0], operands[1])
 	default:
 		return nil, newUnsupportedOperatorError(op.Operator)
 	}
 }
 
-func insertNotOp(s interface{}) ([]interface{}, error) {
-	clause, ok := s.([]interface{})
+//nolint:gochecknoglobals // Constant expressions
+var (
+	trueExpr  = clause.Expr{SQL: "1 = 1"}
+	falseExpr = clause.Expr{SQL: "1 = 0"}
+)
+
+func doNullTest(test parser.NullTest, other interface{}) clause.Expression {
+	//nolint:exhaustive // NoNullTest never gets here
+	switch test {
+	case parser.IsNull:
+		return clause.Eq{Column: toColumn(other), Value: nil}
+	case parser.IsNotNull:
+		return clause.Neq{Column: toColumn(other), Value: nil}
+	case parser.AlwaysTrue:
+		return trueExpr
+	default:
+		return falseExpr
+	}
+}
+
+// doNotEquals makes ne true when only one side is null, OData's ne is IS DISTINCT FROM where SQL's <> is null.
+func doNotEquals(operand0, operand1 interface{}) clause.Expression {
+	neq := clause.Neq{Column: toColumn(operand0), Value: operand1}
+	null0, null1 := canBeNull(operand0), canBeNull(operand1)
+	switch {
+	case null0 && null1:
+		return clause.Or(neq,
+			clause.And(clause.Eq{Column: operand0, Value: nil}, clause.Neq{Column: operand1, Value: nil}),
+			clause.And(clause.Neq{Column: operand0, Value: nil}, clause.Eq{Column: operand1, Value: nil}))
+	case null0:
+		return clause.Or(neq, clause.Eq{Column: operand0, Value: nil})
+	case null1:
+		return clause.Or(neq, clause.Eq{Column: operand1, Value: nil})
+	default:
+		return neq
+	}
+}
+
+// canBeNull is true for columns and expressions, literals are never null by the time they get here.
+func canBeNull(operand interface{}) bool {
+	switch operand.(type) {
+	case clause.Column, clause.Expression:
+		return true
+	default:
+		return false
+	}
+}
+
+// doIn matches a null in the list with IS NULL, SQL's IN never matches null.
+func doIn(operand0, operand1 interface{}) clause.Expression {
+	list, ok := operand1.([]interface{})
 	if !ok {
-		return nil, newUnsupportedOperandError(s)
+		list = []interface{}{operand1}
 	}
-	str, ok := clause[0].(string)
+	values, hasNull := parser.SplitNull(list)
+	column := toColumn(operand0)
+	switch {
+	case !hasNull:
+		return clause.IN{Column: column, Values: values}
+	case len(values) == 0:
+		return clause.Eq{Column: column, Value: nil}
+	default:
+		return clause.Or(clause.IN{Column: column, Values: values}, clause.Eq{Column: column, Value: nil})
+	}
+}
+
+// doFunction fills in a SQL template, the operands can be values, columns or other expressions.
+func doFunction(operator parser.Operator, template string, operands []interface{}) (clause.Expression, error) {
+	count := strings.Count(template, "?")
+	if len(operands) != count {
+		return nil, newOperandCountError(operator, count)
+	}
+	return clause.Expr{SQL: template, Vars: operands}, nil
+}
+
+// doSubstring converts OData's zero based index to SQL's one based one.
+func doSubstring(operands []interface{}) (clause.Expression, error) {
+	switch len(operands) {
+	case 2:
+		return clause.Expr{SQL: "SUBSTR(?, ? + 1)", Vars: operands}, nil
+	case 3:
+		return clause.Expr{SQL: "SUBSTR(?, ? + 1, ?)", Vars: operands}, nil
+	default:
+		return nil, newOperandCountError(lexer.Substring, 2)
+	}
+}
+
+// doDivide is OData's div, which only truncates when both operands are integers. The column types aren't known here
+// so two integer literals are divided now and anything else uses /, which is integer division for two integer columns
+// on most databases. MySQL's / always gives a decimal.
+func doDivide(operands []interface{}) (clause.Expression, error) {
+	if len(operands) != 2 {
+		return nil, newOperandCountError(lexer.Divide, 2)
+	}
+	dividend, ok0 := operands[0].(int)
+	divisor, ok1 := operands[1].(int)
+	if !ok0 || !ok1 {
+		return clause.Expr{SQL: "(? / ?)", Vars: operands}, nil
+	}
+	if divisor == 0 {
+		return nil, newParserError("division by zero")
+	}
+	return clause.Expr{SQL: "?", Vars: []interface{}{dividend / divisor}}, nil
+}
+
+// toColumn makes the left hand side of a comparison, gorm quotes anything it's given there as a column name.
+func toColumn(operand interface{}) interface{} {
+	switch operand.(type) {
+	case clause.Column, clause.Expr:
+		return operand
+	default:
+		return clause.Expr{SQL: "?", Vars: []interface{}{operand}}
+	}
+}
+
+// toCondition checks that an operand is a condition, true and false literals and boolean columns are turned into
+// conditions.
+func toCondition(operand interface{}) (clause.Expression, error) {
+	switch data := operand.(type) {
+	case bool:
+		if data {
+			return trueExpr, nil
+		}
+		return falseExpr, nil
+	case clause.Column:
+		return clause.Eq{Column: data, Value: true}, nil
+	case clause.Expression:
+		return data, nil
+	default:
+		return nil, newUnsupportedOperandError(operand)
+	}
+}
+
+func doConjunction(operator parser.Operator, operands []interface{}) (clause.Expression, error) {
+	conditions := make([]clause.Expression, 0, len(operands))
+	for _, operand := range operands {
+		condition, err := toCondition(operand)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	if operator == lexer.And {
+		return clause.And(conditions...), nil
+	}
+	return clause.Or(conditions...), nil
+}
+
+// notExpr negates a condition. clause.Not negates each part of an AND separately, which isn't what not () means.
+// When the condition can be null it's made false first, otherwise NOT leaves out the rows OData's not keeps.
+type notExpr struct {
+	expr     clause.Expression
+	coalesce bool
+}
+
+func (n notExpr) Build(builder clause.Builder) {
+	if n.coalesce {
+		builder.WriteString("NOT COALESCE(")
+		n.expr.Build(builder)
+		builder.WriteString(", FALSE)")
+		return
+	}
+	if negation, ok := n.expr.(clause.NegationExpressionBuilder); ok {
+		negation.NegationBuild(builder)
+		return
+	}
+	builder.WriteString("NOT ")
+	switch n.expr.(type) {
+	case clause.AndConditions, clause.OrConditions:
+		// These already add their own parentheses
+		n.expr.Build(builder)
+	default:
+		builder.WriteByte('(')
+		n.expr.Build(builder)
+		builder.WriteByte(')')
+	}
+}
+
+func doNot(operand interface{}, coalesce bool) (clause.Expression, error) {
+	condition, err := toCondition(operand)
+	if err != nil {
+		return nil, err
+	}
+	return notExpr{expr: condition, coalesce: coalesce}, nil
+}
+
+// likeExpr is clause.Like with an ESCAPE clause.
+type likeExpr struct {
+	Column interface{}
+	Value  string
+}
+
+func (l likeExpr) Build(builder clause.Builder) {
+	l.build(builder, " LIKE ")
+}
+
+func (l likeExpr) NegationBuild(builder clause.Builder) {
+	l.build(builder, " NOT LIKE ")
+}
+
+func (l likeExpr) build(builder clause.Builder, operator string) {
+	builder.WriteQuoted(l.Column)
+	builder.WriteString(operator)
+	builder.AddVar(builder, l.Value)
+	builder.WriteString(" ESCAPE '" + parser.LikeEscape + "'")
+}
+
+func doLike(prefix, postfix string, operand0, operand1 interface{}) (clause.Expression, error) {
+	str, ok := operand1.(string)
+	if !ok {
+		return nil, newParserError("attempting to do a LIKE with a non-string value")
+	}
+	return likeExpr{Column: toColumn(operand0), Value: prefix + parser.EscapeLike(str) + postfix}, nil
+}
+
+func doHasSubset(operand0, operand1 interface{}) (clause.Expression, error) {
+	values, ok := operand1.([]interface{})
 	if !ok {
-		return nil, newUnsupportedOperandError(s)
+		return nil, newParserError("hassubset requires a collection")
 	}
-	parts := strings.Split(str, " ")
-	if len(parts) >= 2 {
-		ret := []interface{}{parts[0] + " NOT " + strings.Join(parts[1:], " ")}
-		ret = append(ret, clause[1:]...)
-		return ret, nil
+	jsonData, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
 	}
-	ret := []interface{}{"NOT " + str}
-	ret = append(ret, clause[1:]...)
-	return ret, nil
+	// There's no portable way to search a collection, this is understood by MySQL
+	return clause.Expr{SQL: "JSON_CONTAINS(?, ?)", Vars: []interface{}{operand0, string(jsonData)}}, nil
+}
+
+// doHasSubsequence numbers the collection's elements with JSON_TABLE, once for each value, and checks the values are
+// found in order. Like hassubset this is understood by MySQL.
+func doHasSubsequence(operand0, operand1 interface{}) (clause.Expression, error) {
+	values, ok := operand1.([]interface{})
+	if !ok {
+		return nil, newParserError("hassubsequence requires a collection")
+	}
+	if len(values) == 0 {
+		return trueExpr, nil
+	}
+	tables := make([]string, len(values))
+	conds := make([]string, 0, 2*len(values)-1)
+	// The tables' columns come first in the SQL, then the values in the conditions
+	vars := make([]interface{}, len(values), 2*len(values))
+	for i, value := range values {
+		jsonData, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		alias := "s" + strconv.Itoa(i)
+		tables[i] = "JSON_TABLE(?, '$[*]' COLUMNS(i FOR ORDINALITY, v JSON PATH '$')) AS " + alias
+		vars[i] = operand0
+		vars = append(vars, string(jsonData))
+		conds = append(conds, alias+".v = CAST(? AS JSON)")
+		if i > 0 {
+			conds = append(conds, "s"+strconv.Itoa(i-1)+".i < "+alias+".i")
+		}
+	}
+	sql := "EXISTS(SELECT 1 FROM " + strings.Join(tables, ", ") + " WHERE " + strings.Join(conds, " AND ") + ")"
+	return clause.Expr{SQL: sql, Vars: vars}, nil
+}
+
+func (p *Parser) doCase(op *parser.Operation) (clause.Expression, error) {
+	sql := "CASE"
+	vars := make([]interface{}, 0, len(op.Operands))
+	for i := 0; i+1 < len(op.Operands); i += 2 {
+		value, err := p.getGormOperand(op.Operands[i+1])
+		if err != nil {
+			return nil, err
+		}
+		token, ok := op.Operands[i].(*lexer.Token)
+		if ok && token.Type == lexer.TokenTrue {
+			// true is the catch all branch, anything after it can never be reached
+			if i == 0 {
+				return clause.Expr{SQL: "?", Vars: []interface{}{value}}, nil
+			}
+			sql += " ELSE ?"
+			vars = append(vars, value)
+			break
+		}
+		operand, err := p.getGormOperand(op.Operands[i])
+		if err != nil {
+			return nil, err
+		}
+		cond, err := toCondition(operand)
+		if err != nil {
+			return nil, err
+		}
+		sql += " WHEN ? THEN ?"
+		vars = append(vars, cond, value)
+	}
+	return clause.Expr{SQL: sql + " END", Vars: vars}, nil
+}
+
+func (p *Parser) pathColumn(path *parser.PropertyPath) interface{} {
+	last := len(path.Segments) - 1
+	if p.pathStyle == JSONPath {
+		return clause.Expr{SQL: path.Segments[0] + "->>'$." + strings.Join(path.Segments[1:], ".") + "'"}
+	}
+	navigation := strings.Join(path.Segments[:last], "/")
+	alias, ok := p.aliases[navigation]
+	if !ok {
+		alias = strings.Join(path.Segments[:last], "_")
+	}
+	return clause.Column{Table: alias, Name: path.Segments[last]}
 }
 
 func (p *Parser) getGormOperands(operands []parser.Operand) ([]interface{}, error) {
-	ret := make([]interface{}, 0)
+	ret := make([]interface{}, 0, len(operands))
 	for _, operand := range operands {
 		tmp, err := p.getGormOperand(operand)
 		if err != nil {
@@ -120,34 +475,24 @@ func (p *Parser) getGormOperands(operands []parser.Operand) ([]interface{}, erro
 	return ret, nil
 }
 
+// getGormOperand returns a clause.Column for properties, a clause.Expression for operations and the value for literals.
 func (p *Parser) getGormOperand(operand parser.Operand) (interface{}, error) {
+	if token, ok := operand.(*lexer.Token); ok && token.Type == lexer.UnquotedString {
+		return clause.Column{Name: token.Text}, nil
+	}
 	data, err := operand.GetData()
 	if err != nil {
 		return nil, err
 	}
 	switch op := data.(type) {
-	case string, float64, int, map[string]interface{}:
+	case string, float64, int, bool, time.Time, map[string]interface{}, nil:
 		return op, nil
+	case *parser.PropertyPath:
+		return p.pathColumn(op), nil
 	case *parser.Operation:
-		inner, err := p.getGormQuery(op)
-		if err != nil {
-			return nil, err
-		}
-		return inner, nil
+		return p.getGormQuery(op)
 	case []parser.Operand:
-		tmp := make([]interface{}, 0)
-		for _, o := range op {
-			inner, err := p.getGormOperands([]parser.Operand{o})
-			if err != nil {
-				return nil, err
-			}
-			if len(inner) != 1 {
-				tmp = append(tmp, inner)
-			} else {
-				tmp = append(tmp, inner[0])
-			}
-		}
-		return tmp, nil
+		return p.getGormOperands(op)
 	default:
 		return nil, newUnsupportedOperandError(op)
 	}
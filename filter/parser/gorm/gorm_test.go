@@ -5,89 +5,324 @@ import (
 
 	"github.com/pboyd04/godata/filter/parser"
 	"github.com/stretchr/testify/assert"
+	gormio "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/utils/tests"
 
-	_ "github.com/pboyd04/godata/filter/parser/gorm"
+	"github.com/pboyd04/godata/filter/parser/gorm"
 )
 
 type testData struct {
-	input          string
-	expectedOutput []interface{}
+	input        string
+	expectedSQL  string
+	expectedVars []interface{}
 }
 
 //nolint:gochecknoglobals // Just test data
 var testCases = []testData{
 	{
-		input:          "Name eq 'Milk'",
-		expectedOutput: []interface{}{"Name = ?", "Milk"},
+		input:        "Name eq 'Milk'",
+		expectedSQL:  "`Name` = ?",
+		expectedVars: []interface{}{"Milk"},
 	},
 	{
-		input:          "(Name eq 'Milk')",
-		expectedOutput: []interface{}{"Name = ?", "Milk"},
+		input:        "(Name eq 'Milk')",
+		expectedSQL:  "`Name` = ?",
+		expectedVars: []interface{}{"Milk"},
 	},
 	{
-		input:          "Name ne 'Milk'",
-		expectedOutput: []interface{}{"Name != ?", "Milk"},
+		input:        "Name ne 'Milk'",
+		expectedSQL:  "(`Name` <> ? OR `Name` IS NULL)",
+		expectedVars: []interface{}{"Milk"},
 	},
 	{
-		input:          "Name gt 'Milk'",
-		expectedOutput: []interface{}{"Name > ?", "Milk"},
+		input:        "Name gt 'Milk'",
+		expectedSQL:  "`Name` > ?",
+		expectedVars: []interface{}{"Milk"},
 	},
 	{
-		input:          "Name ge 'Milk'",
-		expectedOutput: []interface{}{"Name >= ?", "Milk"},
+		input:        "Name ge 'Milk'",
+		expectedSQL:  "`Name` >= ?",
+		expectedVars: []interface{}{"Milk"},
 	},
 	{
-		input:          "Name lt 'Milk'",
-		expectedOutput: []interface{}{"Name < ?", "Milk"},
+		input:        "Name lt 'Milk'",
+		expectedSQL:  "`Name` < ?",
+		expectedVars: []interface{}{"Milk"},
 	},
 	{
-		input:          "Name le 'Milk'",
-		expectedOutput: []interface{}{"Name <= ?", "Milk"},
+		input:        "Name le 'Milk'",
+		expectedSQL:  "`Name` <= ?",
+		expectedVars: []interface{}{"Milk"},
 	},
 	{
-		input:          "Name eq 'Milk' and Price lt 2.55",
-		expectedOutput: []interface{}{"Name = ? AND Price < ?", "Milk", 2.55},
+		input:        "Name eq 'Milk' and Price lt 2.55",
+		expectedSQL:  "`Name` = ? AND `Price` < ?",
+		expectedVars: []interface{}{"Milk", 2.55},
 	},
 	{
-		input:          "Name EQ 'Milk' AND Price LT 2.55",
-		expectedOutput: []interface{}{"Name = ? AND Price < ?", "Milk", 2.55},
+		input:        "Name EQ 'Milk' AND Price LT 2.55",
+		expectedSQL:  "`Name` = ? AND `Price` < ?",
+		expectedVars: []interface{}{"Milk", 2.55},
 	},
 	{
-		input:          "Name eq 'Milk' AND Price lt 2.55",
-		expectedOutput: []interface{}{"Name = ? AND Price < ?", "Milk", 2.55},
+		input:        "Name eq 'Milk' AND Price lt 2.55",
+		expectedSQL:  "`Name` = ? AND `Price` < ?",
+		expectedVars: []interface{}{"Milk", 2.55},
 	},
 	{
-		input:          "Name eq 'Milk' or Price lt 2.55",
-		expectedOutput: []interface{}{"Name = ? OR Price < ?", "Milk", 2.55},
+		input:        "Name eq 'Milk' or Price lt 2.55",
+		expectedSQL:  "(`Name` = ? OR `Price` < ?)",
+		expectedVars: []interface{}{"Milk", 2.55},
 	},
 	{
-		input:          "Name in ('Milk', 'Cheese')",
-		expectedOutput: []interface{}{"Name IN ?", []interface{}{"Milk", "Cheese"}},
+		input:        "Name in ('Milk', 'Cheese')",
+		expectedSQL:  "`Name` IN (?,?)",
+		expectedVars: []interface{}{"Milk", "Cheese"},
 	},
 	{
-		input:          "Name in ['Milk', 'Cheese']",
-		expectedOutput: []interface{}{"Name IN ?", []interface{}{"Milk", "Cheese"}},
+		input:        "Name in ['Milk', 'Cheese']",
+		expectedSQL:  "`Name` IN (?,?)",
+		expectedVars: []interface{}{"Milk", "Cheese"},
 	},
 	{
-		input:          "contains(Name,'red')",
-		expectedOutput: []interface{}{"Name LIKE ?", "%red%"},
+		input:        "contains(Name,'red')",
+		expectedSQL:  "`Name` LIKE ? ESCAPE '!'",
+		expectedVars: []interface{}{"%red%"},
 	},
 	{
-		input:          `Address eq {"Street":"NE 40th","City":"Redmond","State":"WA","ZipCode":"98052"}`,
-		expectedOutput: []interface{}{"Address = ?", map[string]interface{}{"City": "Redmond", "State": "WA", "Street": "NE 40th", "ZipCode": "98052"}},
+		input:        `Address eq {"Street":"NE 40th","City":"Redmond","State":"WA","ZipCode":"98052"}`,
+		expectedSQL:  "`Address` = ?",
+		expectedVars: []interface{}{map[string]interface{}{"City": "Redmond", "State": "WA", "Street": "NE 40th", "ZipCode": "98052"}},
 	},
 	{
-		input:          "endswith(Name,'ilk')",
-		expectedOutput: []interface{}{"Name LIKE ?", "%ilk"},
+		input:        "contains(Name,'50%_off!')",
+		expectedSQL:  "`Name` LIKE ? ESCAPE '!'",
+		expectedVars: []interface{}{"%50!%!_off!!%"},
 	},
 	{
-		input:          "not endswith(Name,'ilk')",
-		expectedOutput: []interface{}{"Name NOT LIKE ?", "%ilk"},
+		input:        "endswith(Name,'ilk')",
+		expectedSQL:  "`Name` LIKE ? ESCAPE '!'",
+		expectedVars: []interface{}{"%ilk"},
 	},
 	{
-		input:          "startswith(CompanyName,'Futterkiste')",
-		expectedOutput: []interface{}{"CompanyName LIKE ?", "Futterkiste%"},
+		input:        "not endswith(Name,'ilk')",
+		expectedSQL:  "NOT COALESCE(`Name` LIKE ? ESCAPE '!', FALSE)",
+		expectedVars: []interface{}{"%ilk"},
 	},
+	{
+		input:        "startswith(CompanyName,'Futterkiste')",
+		expectedSQL:  "`CompanyName` LIKE ? ESCAPE '!'",
+		expectedVars: []interface{}{"Futterkiste%"},
+	},
+	{
+		input:        "Address/City eq 'Redmond'",
+		expectedSQL:  "`Address`.`City` = ?",
+		expectedVars: []interface{}{"Redmond"},
+	},
+	{
+		input:        "case(Status eq 'A':1, Status eq 'B':2, true:0) gt 0",
+		expectedSQL:  "CASE WHEN `Status` = ? THEN ? WHEN `Status` = ? THEN ? ELSE ? END > ?",
+		expectedVars: []interface{}{"A", 1, "B", 2, 0, 0},
+	},
+	{
+		input:       "Name eq null",
+		expectedSQL: "`Name` IS NULL",
+	},
+	{
+		input:       "Name ne null",
+		expectedSQL: "`Name` IS NOT NULL",
+	},
+	{
+		input:       "true",
+		expectedSQL: "1 = 1",
+	},
+	{
+		input:       "false",
+		expectedSQL: "1 = 0",
+	},
+	{
+		input:        "not (Name eq 'Milk' and Price lt 2.55)",
+		expectedSQL:  "NOT COALESCE((`Name` = ? AND `Price` < ?), FALSE)",
+		expectedVars: []interface{}{"Milk", 2.55},
+	},
+	{
+		input:       "Price gt Cost",
+		expectedSQL: "`Price` > `Cost`",
+	},
+	{
+		input:        "Price add 1 eq 2",
+		expectedSQL:  "(`Price` + ?) = ?",
+		expectedVars: []interface{}{1, 2},
+	},
+	{
+		input:        "Price sub 1 le 2",
+		expectedSQL:  "(`Price` - ?) <= ?",
+		expectedVars: []interface{}{1, 2},
+	},
+	{
+		input:        "Price mul 2 gt 5",
+		expectedSQL:  "(`Price` * ?) > ?",
+		expectedVars: []interface{}{2, 5},
+	},
+	{
+		input:        "Price div 2 lt 1",
+		expectedSQL:  "(`Price` / ?) < ?",
+		expectedVars: []interface{}{2, 1},
+	},
+	{
+		input:        "Price div 2 eq 1.25",
+		expectedSQL:  "(`Price` / ?) = ?",
+		expectedVars: []interface{}{2, 1.25},
+	},
+	{
+		input:        "Price div 2.5 eq 1",
+		expectedSQL:  "(`Price` / ?) = ?",
+		expectedVars: []interface{}{2.5, 1},
+	},
+	{
+		input:        "Price lt 7 div 2",
+		expectedSQL:  "`Price` < ?",
+		expectedVars: []interface{}{3},
+	},
+	{
+		input:        "Price mod 2 eq 1",
+		expectedSQL:  "(`Price` % ?) = ?",
+		expectedVars: []interface{}{2, 1},
+	},
+	{
+		input:        "tolower(Name) eq 'milk'",
+		expectedSQL:  "LOWER(`Name`) = ?",
+		expectedVars: []interface{}{"milk"},
+	},
+	{
+		input:        "toupper(Name) eq 'MILK'",
+		expectedSQL:  "UPPER(`Name`) = ?",
+		expectedVars: []interface{}{"MILK"},
+	},
+	{
+		input:        "trim(Name) eq 'Milk'",
+		expectedSQL:  "TRIM(`Name`) = ?",
+		expectedVars: []interface{}{"Milk"},
+	},
+	{
+		input:        "length(Name) eq 4",
+		expectedSQL:  "CHAR_LENGTH(`Name`) = ?",
+		expectedVars: []interface{}{4},
+	},
+	{
+		input:        "concat(Name,'x') eq 'Milkx'",
+		expectedSQL:  "CONCAT(`Name`, ?) = ?",
+		expectedVars: []interface{}{"x", "Milkx"},
+	},
+	{
+		input:        "indexof(Name,'il') eq 1",
+		expectedSQL:  "(INSTR(`Name`, ?) - 1) = ?",
+		expectedVars: []interface{}{"il", 1},
+	},
+	{
+		input:        "substring(Name,1) eq 'ilk'",
+		expectedSQL:  "SUBSTR(`Name`, ? + 1) = ?",
+		expectedVars: []interface{}{1, "ilk"},
+	},
+	{
+		input:        "substring(Name,1,2) eq 'il'",
+		expectedSQL:  "SUBSTR(`Name`, ? + 1, ?) = ?",
+		expectedVars: []interface{}{1, 2, "il"},
+	},
+	{
+		input:        "contains(tolower(Name),'ilk')",
+		expectedSQL:  "LOWER(`Name`) LIKE ? ESCAPE '!'",
+		expectedVars: []interface{}{"%ilk%"},
+	},
+	{
+		input:        "year(Date) eq 2022",
+		expectedSQL:  "EXTRACT(YEAR FROM `Date`) = ?",
+		expectedVars: []interface{}{2022},
+	},
+	{
+		input:        "month(Date) eq 9",
+		expectedSQL:  "EXTRACT(MONTH FROM `Date`) = ?",
+		expectedVars: []interface{}{9},
+	},
+	{
+		input:        "day(Date) eq 8",
+		expectedSQL:  "EXTRACT(DAY FROM `Date`) = ?",
+		expectedVars: []interface{}{8},
+	},
+	{
+		input:        "hour(Date) eq 4",
+		expectedSQL:  "EXTRACT(HOUR FROM `Date`) = ?",
+		expectedVars: []interface{}{4},
+	},
+	{
+		input:        "minute(Date) eq 0",
+		expectedSQL:  "EXTRACT(MINUTE FROM `Date`) = ?",
+		expectedVars: []interface{}{0},
+	},
+	{
+		input:        "second(Date) eq 0",
+		expectedSQL:  "EXTRACT(SECOND FROM `Date`) = ?",
+		expectedVars: []interface{}{0},
+	},
+	{
+		input:        "round(Price) eq 3",
+		expectedSQL:  "ROUND(`Price`) = ?",
+		expectedVars: []interface{}{3},
+	},
+	{
+		input:        "floor(Price) eq 2",
+		expectedSQL:  "FLOOR(`Price`) = ?",
+		expectedVars: []interface{}{2},
+	},
+	{
+		input:        "ceiling(Price) eq 3",
+		expectedSQL:  "CEILING(`Price`) = ?",
+		expectedVars: []interface{}{3},
+	},
+	{
+		input:        `hassubset(Names,["Milk", "Cheese"])`,
+		expectedSQL:  "JSON_CONTAINS(`Names`, ?)",
+		expectedVars: []interface{}{"[\"Milk\",\"Cheese\"]"},
+	},
+	{
+		input: `hassubsequence(Names,['Milk','Cheese'])`,
+		expectedSQL: "EXISTS(SELECT 1 FROM JSON_TABLE(`Names`, '$[*]' COLUMNS(i FOR ORDINALITY, v JSON PATH '$')) AS s0, " +
+			"JSON_TABLE(`Names`, '$[*]' COLUMNS(i FOR ORDINALITY, v JSON PATH '$')) AS s1 " +
+			"WHERE s0.v = CAST(? AS JSON) AND s1.v = CAST(? AS JSON) AND s0.i < s1.i)",
+		expectedVars: []interface{}{`"Milk"`, `"Cheese"`},
+	},
+	{
+		input:        `matchesPattern(Name,'^M\w+$')`,
+		expectedSQL:  "REGEXP_LIKE(`Name`, ?, 'c')",
+		expectedVars: []interface{}{`^M\w+$`},
+	},
+	{
+		input:        `not matchesPattern(Name,'^M')`,
+		expectedSQL:  "NOT COALESCE(REGEXP_LIKE(`Name`, ?, 'c'), FALSE)",
+		expectedVars: []interface{}{"^M"},
+	},
+	{
+		input:        "Rating divby 2 eq 2.5",
+		expectedSQL:  "(`Rating` / ?) = ?",
+		expectedVars: []interface{}{2, 2.5},
+	},
+}
+
+// render builds the expression the same way gorm's Where does.
+func render(t *testing.T, query interface{}) (string, []interface{}) {
+	t.Helper()
+	expr, ok := query.(clause.Expression)
+	if !ok {
+		t.Fatalf("expected clause.Expression, got %T", query)
+	}
+	db, err := gormio.Open(tests.DummyDialector{}, &gormio.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt := &gormio.Statement{DB: db}
+	clause.Where{Exprs: []clause.Expression{expr}}.Build(stmt)
+	return stmt.SQL.String(), stmt.Vars
 }
 
 func TestGorm(t *testing.T) {
@@ -100,15 +335,49 @@ func TestGorm(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			ptrStr, err := common.GetDBQuery("gorm")
+			res, err := common.GetDBQuery("gorm")
 			if err != nil {
 				t.Fatal(err)
 			}
-			res, ok := ptrStr.([]interface{})
-			if !ok {
-				t.Fatalf("expected []interface, got %T", ptrStr)
-			}
-			assert.ElementsMatch(t, tc.expectedOutput, res)
+			sql, vars := render(t, res)
+			assert.Equal(t, tc.expectedSQL, sql)
+			assert.Equal(t, tc.expectedVars, vars)
 		})
 	}
 }
+
+func TestGormErrors(t *testing.T) {
+	t.Parallel()
+	for _, input := range []string{"substring(Name) eq 'a'", "contains(Name,1)", "hassubset(Names,'a')", "hassubsequence(Names,'a')", "Price lt 1 div 0"} {
+		common, err := parser.NewParser(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = common.GetDBQuery("gorm")
+		assert.Error(t, err, input)
+	}
+}
+
+func TestGormPathStyles(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		parser   *gorm.Parser
+		expected string
+	}{
+		{gorm.NewParser(gorm.JoinAlias, map[string]string{"Customer/Address": "addr"}), "`addr`.`City` = ?"},
+		{gorm.NewParser(gorm.JSONPath, nil), "Customer->>'$.Address.City' = ?"},
+	}
+	common, err := parser.NewParser("Customer/Address/City eq 'Redmond'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range cases {
+		res, err := test.parser.GetDBQuery(common)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sql, vars := render(t, res)
+		assert.Equal(t, test.expected, sql)
+		assert.Equal(t, []interface{}{"Redmond"}, vars)
+	}
+}
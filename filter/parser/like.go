@@ -0,0 +1,16 @@
+package parser
+
+import "strings"
+
+// LikeEscape is the escape character used by the SQL backends in their LIKE clauses. A backslash would need escaping
+// itself in MySQL string literals, so ! is used instead.
+const LikeEscape = "!"
+
+//nolint:gochecknoglobals // The replacer is safe for concurrent use and only needs to be built once
+var likeReplacer = strings.NewReplacer(LikeEscape, LikeEscape+LikeEscape, "%", LikeEscape+"%", "_", LikeEscape+"_")
+
+// EscapeLike escapes the LIKE wildcards in s so that it only matches itself. The clause must include
+// ESCAPE '!' for the escapes to be understood.
+func EscapeLike(s string) string {
+	return likeReplacer.Replace(s)
+}
@@ -3,6 +3,7 @@ package mongodb
 import (
 	"fmt"
 
+	"github.com/pboyd04/godata/errcode"
 	"github.com/pboyd04/godata/filter/parser"
 )
 
@@ -41,3 +42,40 @@ func (e *ParserError) Error() string {
 func newParserError(message string) error {
 	return &ParserError{message: message}
 }
+
+var (
+	ErrUnsupportedValueType = newParserError("unsupported value type")
+	ErrInvalidUUID          = newParserError("a UUID must be 16 bytes")
+)
+
+// InvalidFieldValueError is returned when a value can't be converted to the type the schema gives its field.
+type InvalidFieldValueError struct {
+	Field string
+	Type  FieldType
+	Value interface{}
+	Err   error
+}
+
+func (e *InvalidFieldValueError) Error() string {
+	return fmt.Sprintf("invalid value %#v for %s field %s: %s", e.Value, e.Type, e.Field, e.Err)
+}
+
+func (e *InvalidFieldValueError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnsupportedOperandError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
+
+func (e *UnsupportedOperatorError) ErrorCode() errcode.Code {
+	return errcode.UnsupportedFunction
+}
+
+func (e *ParserError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
+
+func (e *InvalidFieldValueError) ErrorCode() errcode.Code {
+	return errcode.InvalidValue
+}
@@ -0,0 +1,446 @@
+package mongodb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pboyd04/godata/filter/lexer"
+	"github.com/pboyd04/godata/filter/parser"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Query documents can't express computed values, so operations like case() or tolower() are translated into
+// aggregation expressions and wrapped in $expr instead.
+
+//nolint:gochecknoglobals // This is a map of operators that are used in aggregation expressions it's easier to keep it this way than to have a bunch of if statements
+var exprOperators = map[parser.Operator]string{
+	lexer.Equals:             "$eq",
+	lexer.NotEquals:          "$ne",
+	lexer.GreaterThan:        "$gt",
+	lexer.GreaterThanOrEqual: "$gte",
+	lexer.LessThan:           "$lt",
+	lexer.LessThanOrEqual:    "$lte",
+	lexer.And:                "$and",
+	lexer.Or:                 "$or",
+	lexer.Not:                "$not",
+	lexer.In:                 "$in",
+	lexer.Add:                "$add",
+	lexer.Subtract:           "$subtract",
+	lexer.Multiply:           "$multiply",
+	lexer.DivideFloat:        "$divide",
+	lexer.Modulo:             "$mod",
+	lexer.Concat:             "$concat",
+	lexer.IndexOf:            "$indexOfCP",
+}
+
+// exprFunctions are the single argument functions that map directly to an aggregation operator.
+//
+//nolint:gochecknoglobals // This is a map of operators that are used in aggregation expressions it's easier to keep it this way than to have a bunch of if statements
+var exprFunctions = map[parser.Operator]string{
+	lexer.ToLower:           "$toLower",
+	lexer.ToUpper:           "$toUpper",
+	lexer.Year:              "$year",
+	lexer.Month:             "$month",
+	lexer.Day:               "$dayOfMonth",
+	lexer.Hour:              "$hour",
+	lexer.Minute:            "$minute",
+	lexer.Second:            "$second",
+	lexer.FractionalSeconds: "$millisecond",
+	lexer.Floor:             "$floor",
+	lexer.Ceiling:           "$ceil",
+}
+
+// needsExpr returns true if the operation can only be expressed as an aggregation expression.
+func needsExpr(op *parser.Operation) bool {
+	//nolint:exhaustive // Only the operators without a query document equivalent matter here
+	switch op.Operator {
+	case lexer.MatchesPattern, lexer.HasSubsequence:
+		return true
+	}
+	for _, operand := range op.Operands {
+		child, ok := operand.(*parser.Operation)
+		if !ok {
+			continue
+		}
+		if isComputed(child) {
+			return true
+		}
+		if child.Operator == lexer.Length && needsExpr(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// isComputed returns true for operations that produce a value rather than a condition.
+func isComputed(op *parser.Operation) bool {
+	//nolint:exhaustive // Only the computed operators matter here
+	switch op.Operator {
+	case lexer.Case, lexer.Trim, lexer.Substring, lexer.Divide, lexer.Round:
+		return true
+	case lexer.Equals, lexer.NotEquals, lexer.GreaterThan, lexer.GreaterThanOrEqual, lexer.LessThan,
+		lexer.LessThanOrEqual, lexer.And, lexer.Or, lexer.Not, lexer.In:
+		return false
+	}
+	_, ok := exprOperators[op.Operator]
+	if ok {
+		return true
+	}
+	_, ok = exprFunctions[op.Operator]
+	return ok
+}
+
+func (p *Parser) getMongoExpr(operand parser.Operand) (interface{}, error) {
+	switch op := operand.(type) {
+	case *lexer.Token:
+		if op.Type == lexer.UnquotedString {
+			return "$" + op.Text, nil
+		}
+		data, err := op.GetData()
+		if err != nil {
+			return nil, err
+		}
+		str, ok := data.(string)
+		if ok && strings.HasPrefix(str, "$") {
+			// Don't let a string literal be mistaken for a field path
+			return bson.D{{Key: "$literal", Value: str}}, nil
+		}
+		return data, nil
+	case *parser.PropertyPath:
+		return "$" + strings.Join(op.Segments, "."), nil
+	case *parser.SliceOperand:
+		if literal, ok := literalSlice(op); ok {
+			// A bare array would be read as the operator's argument list, i.e. {$size: [1, 2]}
+			return bson.D{{Key: "$literal", Value: literal}}, nil
+		}
+		arr := make([]interface{}, 0, len(op.Slice))
+		for _, o := range op.Slice {
+			inner, err := p.getMongoExpr(o)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, inner)
+		}
+		return arr, nil
+	case *parser.Operation:
+		return p.getMongoExprOperation(op)
+	default:
+		return nil, newUnsupportedOperandError(operand)
+	}
+}
+
+// literalSlice returns the values of a slice made up only of literals.
+func literalSlice(op *parser.SliceOperand) ([]interface{}, bool) {
+	ret := make([]interface{}, 0, len(op.Slice))
+	for _, o := range op.Slice {
+		token, ok := o.(*lexer.Token)
+		if !ok || token.Type == lexer.UnquotedString {
+			return nil, false
+		}
+		data, err := token.GetData()
+		if err != nil {
+			return nil, false
+		}
+		ret = append(ret, data)
+	}
+	return ret, true
+}
+
+//nolint:cyclop // This is just a big switch statement
+func (p *Parser) getMongoExprOperation(op *parser.Operation) (interface{}, error) {
+	//nolint:exhaustive // This won't cover everything and will use the default case to catch errors
+	switch op.Operator {
+	case lexer.TokenTrue:
+		return true, nil
+	case lexer.TokenFalse:
+		return false, nil
+	case lexer.Case:
+		return p.doSwitch(op)
+	}
+	args, err := p.getMongoExprArgs(op)
+	if err != nil {
+		return nil, err
+	}
+	err = p.coerceExprArgs(op, args)
+	if err != nil {
+		return nil, err
+	}
+	if test, other := parser.CompareNull(op.Operator, args); test != parser.NoNullTest {
+		return exprNullTest(test, other), nil
+	}
+	//nolint:exhaustive // This won't cover everything and will use the default case to catch errors
+	switch op.Operator {
+	case lexer.Divide:
+		return doIntegerDivide(args)
+	case lexer.Length:
+		// length() works on both strings and collections
+		return bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$isArray", Value: args[0]}},
+			bson.D{{Key: "$size", Value: args[0]}},
+			bson.D{{Key: "$strLenCP", Value: args[0]}},
+		}}}, nil
+	case lexer.Trim:
+		return bson.D{{Key: "$trim", Value: bson.D{{Key: "input", Value: args[0]}}}}, nil
+	case lexer.Substring:
+		return doSubstring(args)
+	case lexer.Round:
+		return doRound(args[0]), nil
+	case lexer.Contains, lexer.StartsWith, lexer.EndsWith, lexer.MatchesPattern, lexer.HasSubset, lexer.HasSubsequence:
+		return doBinaryFunction(op.Operator, args)
+	}
+	if key, ok := exprFunctions[op.Operator]; ok {
+		return bson.D{{Key: key, Value: args[0]}}, nil
+	}
+	key, ok := exprOperators[op.Operator]
+	if !ok {
+		return nil, newUnsupportedOperatorError(op.Operator)
+	}
+	return guardOrdered(op.Operator, args, bson.D{{Key: key, Value: args}}), nil
+}
+
+// exprNullTest is doNullTest for expressions. Unlike a query document $eq doesn't treat a missing field as null, so
+// $ifNull turns it into one first.
+func exprNullTest(test parser.NullTest, other interface{}) interface{} {
+	//nolint:exhaustive // NoNullTest never gets here
+	switch test {
+	case parser.IsNull:
+		return bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{other, nil}}}, nil}}}
+	case parser.IsNotNull:
+		return bson.D{{Key: "$ne", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{other, nil}}}, nil}}}
+	case parser.AlwaysTrue:
+		return true
+	default:
+		return false
+	}
+}
+
+// guardOrdered stops null passing lt and le, or being passed by gt and ge. BSON orders null before everything else but
+// OData says only null is ordered against null.
+func guardOrdered(operator parser.Operator, args []interface{}, expr interface{}) interface{} {
+	var lower, upper interface{}
+	//nolint:exhaustive // Only ordered comparisons need a guard
+	switch operator {
+	case lexer.LessThan, lexer.LessThanOrEqual:
+		lower, upper = args[0], args[1]
+	case lexer.GreaterThan, lexer.GreaterThanOrEqual:
+		lower, upper = args[1], args[0]
+	default:
+		return expr
+	}
+	if !isFieldExpr(lower) {
+		return expr
+	}
+	guarded := bson.D{{Key: "$and", Value: bson.A{exprNullTest(parser.IsNotNull, lower), expr}}}
+	if (operator == lexer.LessThanOrEqual || operator == lexer.GreaterThanOrEqual) && isFieldExpr(upper) {
+		// null le null is true
+		return bson.D{{Key: "$or", Value: bson.A{
+			guarded,
+			bson.D{{Key: "$and", Value: bson.A{exprNullTest(parser.IsNull, lower), exprNullTest(parser.IsNull, upper)}}},
+		}}}
+	}
+	return guarded
+}
+
+// isFieldExpr is true for field paths and expressions, anything that might turn out to be null.
+func isFieldExpr(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.HasPrefix(v, "$")
+	case bson.D, bson.A:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Parser) getMongoExprArgs(op *parser.Operation) ([]interface{}, error) {
+	err := requireArgs(op.Operands, 1, op.Operator)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(op.Operands))
+	for _, operand := range op.Operands {
+		arg, err := p.getMongoExpr(operand)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// coerceExprArgs applies the schema when a field is compared to a value inside an expression.
+func (p *Parser) coerceExprArgs(op *parser.Operation, args []interface{}) error {
+	if len(args) < 2 {
+		return nil
+	}
+	var field string
+	switch operand := op.Operands[0].(type) {
+	case *lexer.Token:
+		if operand.Type != lexer.UnquotedString {
+			return nil
+		}
+		field = operand.Text
+	case *parser.PropertyPath:
+		field = strings.Join(operand.Segments, ".")
+	default:
+		return nil
+	}
+	value := args[1]
+	doc, isDoc := value.(bson.D)
+	if isDoc {
+		if len(doc) != 1 || doc[0].Key != "$literal" {
+			return nil
+		}
+		value = doc[0].Value
+	}
+	operands := []interface{}{field, value}
+	err := p.coerceOperands(op.Operator, operands)
+	if err != nil {
+		return err
+	}
+	if isDoc {
+		args[1] = bson.D{{Key: "$literal", Value: operands[1]}}
+	} else {
+		args[1] = operands[1]
+	}
+	return nil
+}
+
+func requireArgs[T any](args []T, count int, operator parser.Operator) error {
+	if len(args) < count {
+		return newParserError(fmt.Sprintf("%s requires at least %d operands", lexer.TokenKey(operator), count))
+	}
+	return nil
+}
+
+// doIntegerDivide matches OData's div, which truncates when both sides are integers. $divide always returns a double.
+func doIntegerDivide(args []interface{}) (interface{}, error) {
+	err := requireArgs(args, 2, lexer.Divide)
+	if err != nil {
+		return nil, err
+	}
+	divide := bson.D{{Key: "$divide", Value: args}}
+	integerTypes := bson.A{"int", "long"}
+	bothIntegers := bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "$in", Value: bson.A{bson.D{{Key: "$type", Value: args[0]}}, integerTypes}}},
+		bson.D{{Key: "$in", Value: bson.A{bson.D{{Key: "$type", Value: args[1]}}, integerTypes}}},
+	}}}
+	return bson.D{{Key: "$cond", Value: bson.A{bothIntegers, bson.D{{Key: "$trunc", Value: divide}}, divide}}}, nil
+}
+
+// doSubstring clamps the start and length like the golang evaluator does, $substrCP fails on negative values.
+func doSubstring(args []interface{}) (interface{}, error) {
+	err := requireArgs(args, 2, lexer.Substring)
+	if err != nil {
+		return nil, err
+	}
+	start := nonNegative(args[1])
+	var length interface{}
+	if len(args) > 2 {
+		length = nonNegative(args[2])
+	} else {
+		// Anything at least as long as the rest of the string
+		length = bson.D{{Key: "$strLenCP", Value: args[0]}}
+	}
+	return bson.D{{Key: "$substrCP", Value: bson.A{args[0], start, length}}}, nil
+}
+
+func nonNegative(value interface{}) interface{} {
+	if i, ok := value.(int); ok {
+		if i < 0 {
+			return 0
+		}
+		return i
+	}
+	return bson.D{{Key: "$max", Value: bson.A{value, 0}}}
+}
+
+// doRound rounds half away from zero, $round rounds half to even.
+func doRound(value interface{}) interface{} {
+	return bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$gte", Value: bson.A{value, 0}}},
+		bson.D{{Key: "$floor", Value: bson.D{{Key: "$add", Value: bson.A{value, 0.5}}}}},
+		bson.D{{Key: "$ceil", Value: bson.D{{Key: "$subtract", Value: bson.A{value, 0.5}}}}},
+	}}}
+}
+
+func doBinaryFunction(operator parser.Operator, args []interface{}) (interface{}, error) {
+	err := requireArgs(args, 2, operator)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:exhaustive // Only called for the functions below
+	switch operator {
+	case lexer.Contains:
+		return bson.D{{Key: "$gte", Value: bson.A{bson.D{{Key: "$indexOfCP", Value: args}}, 0}}}, nil
+	case lexer.StartsWith:
+		return bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$indexOfCP", Value: args}}, 0}}}, nil
+	case lexer.EndsWith:
+		return doEndsWith(args), nil
+	case lexer.MatchesPattern:
+		return bson.D{{Key: "$regexMatch", Value: bson.D{{Key: "input", Value: args[0]}, {Key: "regex", Value: args[1]}}}}, nil
+	case lexer.HasSubset:
+		return bson.D{{Key: "$setIsSubset", Value: bson.A{args[1], args[0]}}}, nil
+	default:
+		return doHasSubsequence(args), nil
+	}
+}
+
+func doEndsWith(args []interface{}) interface{} {
+	suffixLen := bson.D{{Key: "$strLenCP", Value: args[1]}}
+	start := bson.D{{Key: "$subtract", Value: bson.A{bson.D{{Key: "$strLenCP", Value: args[0]}}, suffixLen}}}
+	return bson.D{{Key: "$let", Value: bson.D{
+		{Key: "vars", Value: bson.D{{Key: "start", Value: start}}},
+		{Key: "in", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$lt", Value: bson.A{"$$start", 0}}},
+			false,
+			bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$substrCP", Value: bson.A{args[0], "$$start", suffixLen}}}, args[1]}}},
+		}}}},
+	}}}
+}
+
+// doHasSubsequence walks the collection keeping a count of how much of the sequence has been matched so far.
+func doHasSubsequence(args []interface{}) interface{} {
+	sequenceLen := bson.D{{Key: "$size", Value: args[1]}}
+	matched := bson.D{{Key: "$reduce", Value: bson.D{
+		{Key: "input", Value: args[0]},
+		{Key: "initialValue", Value: 0},
+		{Key: "in", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "$lt", Value: bson.A{"$$value", sequenceLen}}},
+				bson.D{{Key: "$eq", Value: bson.A{"$$this", bson.D{{Key: "$arrayElemAt", Value: bson.A{args[1], "$$value"}}}}}},
+			}}},
+			bson.D{{Key: "$add", Value: bson.A{"$$value", 1}}},
+			"$$value",
+		}}}},
+	}}}
+	return bson.D{{Key: "$eq", Value: bson.A{matched, sequenceLen}}}
+}
+
+func (p *Parser) doSwitch(op *parser.Operation) (interface{}, error) {
+	branches := make([]interface{}, 0, len(op.Operands)/2)
+	var defaultValue interface{}
+	for i := 0; i+1 < len(op.Operands); i += 2 {
+		value, err := p.getMongoExpr(op.Operands[i+1])
+		if err != nil {
+			return nil, err
+		}
+		token, ok := op.Operands[i].(*lexer.Token)
+		if ok && token.Type == lexer.TokenTrue {
+			// true is the catch all branch, anything after it can never be reached
+			if i == 0 {
+				return value, nil
+			}
+			defaultValue = value
+			break
+		}
+		cond, err := p.getMongoExpr(op.Operands[i])
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, bson.D{{Key: "case", Value: cond}, {Key: "then", Value: value}})
+	}
+	// Without a default $switch raises an error when nothing matches, OData says the result is null
+	return bson.D{{Key: "$switch", Value: bson.D{{Key: "branches", Value: branches}, {Key: "default", Value: defaultValue}}}}, nil
+}
@@ -1,20 +1,29 @@
 package mongodb
 
 import (
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pboyd04/godata/filter/lexer"
 	"github.com/pboyd04/godata/filter/parser"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Parser struct {
+	schema Schema
 }
 
 func init() {
 	// Register the parser
-	parser.RegisterParser("mongodb", &Parser{})
+	parser.RegisterParser("mongodb", NewParser(DefaultSchema()))
+}
+
+// NewParser returns a parser that converts values to the BSON types in the schema. Register it under its own name or
+// pass it to Filter.GetDBQueryWithParser to use a different schema for each collection.
+func NewParser(schema Schema) *Parser {
+	return &Parser{schema: schema}
 }
 
 func (p *Parser) GetDBQuery(common *parser.Parser) (interface{}, error) {
@@ -39,27 +48,30 @@ func (p *Parser) GetDBQueryWithReplacement(common *parser.Parser, a ...interface
 
 //nolint:funlen,cyclop
 func (p *Parser) getMongoQuery(op *parser.Operation) (bson.D, error) {
+	if needsExpr(op) {
+		expr, err := p.getMongoExprOperation(op)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{{Key: "$expr", Value: expr}}, nil
+	}
 	operands, err := p.getMongoOperands(op.Operands)
 	if err != nil {
 		return nil, err
 	}
-	if len(operands) > 1 {
-		op, ok := operands[0].(string)
-		if ok && op == "_id" {
-			// convert the id to an oid doc...
-			oid, err := primitive.ObjectIDFromHex(operands[1].(string))
-			if err != nil {
-				return nil, err
-			}
-			operands[1] = oid
-		}
+	err = p.coerceOperands(op.Operator, operands)
+	if err != nil {
+		return nil, err
+	}
+	if test, other := parser.CompareNull(op.Operator, operands); test != parser.NoNullTest {
+		return doNullTest(test, other)
 	}
 	//nolint:exhaustive // This won't cover everything and will use the default case to catch errors
 	switch op.Operator {
 	case lexer.TokenTrue:
 		return bson.D{}, nil
 	case lexer.TokenFalse:
-		return bson.D{{Key: "_id", Value: bson.D{{Key: "$type", Value: "string"}}}}, nil
+		return falseQuery(), nil
 	case lexer.Equals:
 		return doSimpleOp("$eq", operands[0], operands[1])
 	case lexer.NotEquals:
@@ -104,6 +116,31 @@ func (p *Parser) getMongoQuery(op *parser.Operation) (bson.D, error) {
 	}
 }
 
+// falseQuery matches nothing.
+func falseQuery() bson.D {
+	return bson.D{{Key: "$expr", Value: false}}
+}
+
+// doNullTest uses $eq: null for null checks, which also matches documents without the field. $ne: null already excludes
+// those, $exists makes that explicit.
+func doNullTest(test parser.NullTest, other interface{}) (bson.D, error) {
+	//nolint:exhaustive // NoNullTest never gets here
+	switch test {
+	case parser.IsNull:
+		return doSimpleOp("$eq", other, nil)
+	case parser.IsNotNull:
+		field, ok := other.(string)
+		if !ok {
+			return nil, newParserError("Attempting to do a null check on an unknown field")
+		}
+		return bson.D{{Key: field, Value: bson.D{{Key: "$exists", Value: true}, {Key: "$ne", Value: nil}}}}, nil
+	case parser.AlwaysTrue:
+		return bson.D{}, nil
+	default:
+		return falseQuery(), nil
+	}
+}
+
 func doSimpleOp(key string, operand0, operand1 interface{}) (bson.D, error) {
 	switch op0Data := operand0.(type) {
 	case string:
@@ -145,7 +182,7 @@ func doRegExOp(prefix, postfix string, operand0, operand1 interface{}) (bson.D,
 	if !ok {
 		return nil, newParserError("Attempting to do a regex with a non-string value")
 	}
-	regEx := prefix + strOp1 + postfix
+	regEx := prefix + regexp.QuoteMeta(strOp1) + postfix
 	return bson.D{{Key: strOp0, Value: bson.D{{Key: "$regex", Value: regEx}}}}, nil
 }
 
@@ -167,8 +204,10 @@ func (p *Parser) getMongoOperand(operand parser.Operand) (interface{}, error) {
 		return nil, err
 	}
 	switch op := data.(type) {
-	case string, float64, int, map[string]interface{}:
+	case string, float64, int, bool, time.Time, map[string]interface{}, nil:
 		return op, nil
+	case *parser.PropertyPath:
+		return strings.Join(op.Segments, "."), nil
 	case *parser.Operation:
 		inner, err := p.getMongoQuery(op)
 		if err != nil {
@@ -3,8 +3,14 @@ package mongodb_test
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
+	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/filter"
 	"github.com/pboyd04/godata/filter/parser"
+	"github.com/pboyd04/godata/filter/parser/mongodb"
+	"github.com/pboyd04/godata/orderby"
+	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -28,7 +34,7 @@ var testCases = []testData{
 	},
 	{
 		input:                 "false",
-		expectedMongoJSONText: `{"_id":{"$type":"string"}}`,
+		expectedMongoJSONText: `{"$expr":false}`,
 	},
 	{
 		input:                 "Name eq 'Milk'",
@@ -82,6 +88,14 @@ var testCases = []testData{
 		input:                 "Name in ['Milk', 'Cheese']",
 		expectedMongoJSONText: `{"Name":{"$in":["Milk","Cheese"]}}`,
 	},
+	{
+		input:                 "BirthDate gt 2020-01-01",
+		expectedMongoJSONText: `{"BirthDate":{"$gt":"2020-01-01"}}`,
+	},
+	{
+		input:                 "Id eq 01234567-89ab-cdef-0123-456789abcdef",
+		expectedMongoJSONText: `{"Id":{"$eq":"01234567-89ab-cdef-0123-456789abcdef"}}`,
+	},
 	{
 		input:                 "_id eq 6206b158000e1859781d5e16",
 		expectedMongoJSONText: `{"_id":{"$eq":{"$oid":"6206b158000e1859781d5e16"}}}`,
@@ -94,6 +108,10 @@ var testCases = []testData{
 		input:                 `Address eq {"Street":"NE 40th","City":"Redmond","State":"WA","ZipCode":"98052"}`,
 		expectedMongoJSONText: `{"Address":{"$eq":{"City":"Redmond","State":"WA","Street":"NE 40th","ZipCode":"98052"}}}`,
 	},
+	{
+		input:                 "contains(Name,'.*')",
+		expectedMongoJSONText: `{"Name":{"$regex":"\\.\\*"}}`,
+	},
 	{
 		input:                 "endswith(Name,'ilk')",
 		expectedMongoJSONText: `{"Name":{"$regex":"ilk$"}}`,
@@ -115,6 +133,74 @@ var testCases = []testData{
 		input:                 `hassubset(Names,["Milk", "Cheese"])`,
 		expectedMongoJSONText: `{"Names":{"$all":["Milk","Cheese"]}}`,
 	},
+	{
+		input:                 `Address/City eq 'Redmond'`,
+		expectedMongoJSONText: `{"Address.City":{"$eq":"Redmond"}}`,
+	},
+	{
+		input:                 `case(Status eq 'A':1, Status eq 'B':2, true:0) gt 0`,
+		expectedMongoJSONText: `{"$expr":{"$gt":[{"$switch":{"branches":[{"case":{"$eq":["$Status","A"]},"then":1},{"case":{"$eq":["$Status","B"]},"then":2}],"default":0}},0]}}`,
+	},
+	{
+		input:                 `Price add 1 gt 3`,
+		expectedMongoJSONText: `{"$expr":{"$gt":[{"$add":["$Price",1]},3]}}`,
+	},
+	{
+		input:                 `Price div 2 eq 1`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$cond":[{"$and":[{"$in":[{"$type":"$Price"},["int","long"]]},{"$in":[{"$type":2},["int","long"]]}]},{"$trunc":{"$divide":["$Price",2]}},{"$divide":["$Price",2]}]},1]}}`,
+	},
+	{
+		input:                 `tolower(Name) eq 'milk'`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$toLower":"$Name"},"milk"]}}`,
+	},
+	{
+		input:                 `trim(Name) eq 'a'`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$trim":{"input":"$Name"}},"a"]}}`,
+	},
+	{
+		input:                 `concat(Name,'x') eq 'ax'`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$concat":["$Name","x"]},"ax"]}}`,
+	},
+	{
+		input:                 `indexof(Name,'il') eq 1`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$indexOfCP":["$Name","il"]},1]}}`,
+	},
+	{
+		input:                 `substring(Name,1,2) eq 'il'`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$substrCP":["$Name",1,2]},"il"]}}`,
+	},
+	{
+		input:                 `matchesPattern(Name,'^M')`,
+		expectedMongoJSONText: `{"$expr":{"$regexMatch":{"input":"$Name","regex":"^M"}}}`,
+	},
+	{
+		input:                 `hassubsequence(Tags,['a','b'])`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$reduce":{"in":{"$cond":[{"$and":[{"$lt":["$$value",{"$size":{"$literal":["a","b"]}}]},{"$eq":["$$this",{"$arrayElemAt":[{"$literal":["a","b"]},"$$value"]}]}]},{"$add":["$$value",1]},"$$value"]},"initialValue":0,"input":"$Tags"}},{"$size":{"$literal":["a","b"]}}]}}`,
+	},
+	{
+		input:                 `round(Price) eq 3`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$cond":[{"$gte":["$Price",0]},{"$floor":{"$add":["$Price",0.5]}},{"$ceil":{"$subtract":["$Price",0.5]}}]},3]}}`,
+	},
+	{
+		input:                 `year(Date) eq 2022`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$year":"$Date"},2022]}}`,
+	},
+	{
+		input:                 `length(tolower(Name)) eq 4`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$cond":[{"$isArray":{"$toLower":"$Name"}},{"$size":{"$toLower":"$Name"}},{"$strLenCP":{"$toLower":"$Name"}}]},4]}}`,
+	},
+	{
+		input:                 `contains(tolower(Name),'ilk')`,
+		expectedMongoJSONText: `{"$expr":{"$gte":[{"$indexOfCP":[{"$toLower":"$Name"},"ilk"]},0]}}`,
+	},
+	{
+		input:                 `Name eq 'Milk' and year(Date) eq 2022`,
+		expectedMongoJSONText: `{"$and":[{"Name":{"$eq":"Milk"}},{"$expr":{"$eq":[{"$year":"$Date"},2022]}}]}`,
+	},
+	{
+		input:                 `Price mod 2 eq 1`,
+		expectedMongoJSONText: `{"$expr":{"$eq":[{"$mod":["$Price",2]},1]}}`,
+	},
 }
 
 //nolint:gochecknoglobals // Just test data
@@ -131,6 +217,18 @@ var testCasesReplace = []testDataReplace{
 		replacements:          []interface{}{"test", 2025},
 		expectedReplacedText:  `{"$and":[{"year":{"$eq":2025}},{"id":{"$eq":"test"}}]}`,
 	},
+	{
+		input:                 "active eq ':0' and created lt ':1'",
+		expectedMongoJSONText: `{"$and":[{"active":{"$eq":":0"}},{"created":{"$lt":":1"}}]}`,
+		replacements:          []interface{}{true, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		expectedReplacedText:  `{"$and":[{"active":{"$eq":true}},{"created":{"$lt":{"$date":"2024-01-02T03:04:05Z"}}}]}`,
+	},
+	{
+		input:                 "name in (':0')",
+		expectedMongoJSONText: `{"name":{"$in":[":0"]}}`,
+		replacements:          []interface{}{[]string{"Milk", "Cheese"}},
+		expectedReplacedText:  `{"name":{"$in":["Milk","Cheese"]}}`,
+	},
 }
 
 func TestMongo(t *testing.T) {
@@ -248,3 +346,176 @@ func BenchmarkReplacement(b *testing.B) {
 		})
 	}
 }
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+	orderBy, err := orderby.NewOrderBy("Price desc,Address/City")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		opts     *odata.QueryOptions
+		expected []string
+	}{
+		{
+			name:     "empty",
+			opts:     odata.NewQueryOptions(),
+			expected: []string{},
+		},
+		{
+			name: "all",
+			opts: &odata.QueryOptions{
+				Filter:  filter.MustCompile("Name eq 'Milk'"),
+				OrderBy: orderBy,
+				Skip:    10,
+				Top:     5,
+				Select:  &[]string{"Name", "Address/City"},
+			},
+			expected: []string{
+				`{"$match":{"Name":{"$eq":"Milk"}}}`,
+				`{"$sort":{"Price":-1,"Address.City":1,"_id":1}}`,
+				`{"$skip":10}`,
+				`{"$limit":5}`,
+				`{"$project":{"Name":1,"Address.City":1,"_id":0}}`,
+			},
+		},
+		{
+			name: "count",
+			opts: &odata.QueryOptions{Filter: filter.MustCompile("true"), Top: 2, Count: true},
+			expected: []string{
+				`{"$facet":{"items":[{"$limit":2}],"count":[{"$count":"count"}]}}`,
+				`{"$project":{"items":1,"count":{"$ifNull":[{"$arrayElemAt":["$count.count",0]},0]}}}`,
+			},
+		},
+		{
+			name: "top zero",
+			opts: &odata.QueryOptions{Top: 0, Count: true},
+			expected: []string{
+				`{"$facet":{"items":[{"$match":{"$expr":false}}],"count":[{"$count":"count"}]}}`,
+				`{"$project":{"items":1,"count":{"$ifNull":[{"$arrayElemAt":["$count.count",0]},0]}}}`,
+			},
+		},
+		{
+			name: "count without paging",
+			opts: &odata.QueryOptions{Select: &[]string{"*"}, Top: -1, Count: true},
+			expected: []string{
+				`{"$facet":{"items":[{"$match":{}}],"count":[{"$count":"count"}]}}`,
+				`{"$project":{"items":1,"count":{"$ifNull":[{"$arrayElemAt":["$count.count",0]},0]}}}`,
+			},
+		},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			pipeline, err := mongodb.NewPipeline(tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stages := make([]string, 0, len(pipeline))
+			for _, stage := range pipeline {
+				bytes, err := bson.MarshalExtJSON(stage, false, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				stages = append(stages, string(bytes))
+			}
+			assert.Equal(t, tc.expected, stages)
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+	schema := mongodb.Schema{
+		"_id":        mongodb.ObjectID,
+		"ownerId":    mongodb.ObjectID,
+		"created":    mongodb.DateTime,
+		"price":      mongodb.Decimal128,
+		"key":        mongodb.UUID,
+		"meta.owner": mongodb.ObjectID,
+	}
+	tests := []struct {
+		input        string
+		replacements []interface{}
+		expected     string
+		err          bool
+	}{
+		{
+			input:    "ownerId in ('6206b158000e1859781d5e16', '6206b158000e1859781d5e17')",
+			expected: `{"ownerId":{"$in":[{"$oid":"6206b158000e1859781d5e16"},{"$oid":"6206b158000e1859781d5e17"}]}}`,
+		},
+		{
+			input:    "meta/owner ne '6206b158000e1859781d5e16'",
+			expected: `{"meta.owner":{"$ne":{"$oid":"6206b158000e1859781d5e16"}}}`,
+		},
+		{
+			input:    "created ge '2024-01-02T03:04:05Z'",
+			expected: `{"created":{"$gte":{"$date":"2024-01-02T03:04:05Z"}}}`,
+		},
+		{
+			input:    "price lt 2.55",
+			expected: `{"price":{"$lt":{"$numberDecimal":"2.55"}}}`,
+		},
+		{
+			input:    "key eq '0f8fad5b-d9cb-469f-a165-70867728950e'",
+			expected: `{"key":{"$eq":{"$binary":{"base64":"D4+tW9nLRp+hZXCGdyiVDg==","subType":"04"}}}}`,
+		},
+		{
+			input:    "ownerId eq null",
+			expected: `{"ownerId":{"$eq":null}}`,
+		},
+		{
+			input:        "ownerId eq ':0'",
+			replacements: []interface{}{"6206b158000e1859781d5e16"},
+			expected:     `{"ownerId":{"$eq":{"$oid":"6206b158000e1859781d5e16"}}}`,
+		},
+		{
+			input:    "year(created) eq 2024 and ownerId eq '6206b158000e1859781d5e16'",
+			expected: `{"$and":[{"$expr":{"$eq":[{"$year":"$created"},2024]}},{"ownerId":{"$eq":{"$oid":"6206b158000e1859781d5e16"}}}]}`,
+		},
+		{
+			input: "_id eq 5",
+			err:   true,
+		},
+		{
+			input: "ownerId eq 'not an id'",
+			err:   true,
+		},
+		{
+			input: "key eq 'abcd'",
+			err:   true,
+		},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			f, err := filter.NewFilter(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.replacements != nil {
+				f, err = f.GetCopyWithReplacements(tc.replacements...)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			res, err := f.GetDBQueryWithParser(mongodb.NewParser(schema))
+			if tc.err {
+				var invalid *mongodb.InvalidFieldValueError
+				assert.ErrorAs(t, err, &invalid)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			bytes, err := bson.MarshalExtJSON(res, false, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.expected, string(bytes))
+		})
+	}
+}
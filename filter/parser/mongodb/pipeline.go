@@ -0,0 +1,133 @@
+package mongodb
+
+import (
+	"strings"
+
+	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/orderby"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PageResult is the document produced by a pipeline built with $count=true.
+type PageResult[T any] struct {
+	Items []T   `bson:"items"`
+	Count int64 `bson:"count"`
+}
+
+// NewPipeline turns QueryOptions into an aggregation pipeline. The stages are $match, $sort, $skip, $limit and
+// $project in that order, a negative $top is treated as not being set. MongoDB doesn't allow a $limit of 0, a $top of 0
+// is a $match that nothing passes instead. When Count is set the paged
+// stages run inside a $facet and the pipeline produces a single document that decodes into a PageResult. The filter
+// uses the default schema, see Parser.Pipeline to use another one.
+func NewPipeline(opts *odata.QueryOptions) (mongo.Pipeline, error) {
+	return NewParser(DefaultSchema()).Pipeline(opts)
+}
+
+// Pipeline is NewPipeline using the parser's schema for the filter.
+func (p *Parser) Pipeline(opts *odata.QueryOptions) (mongo.Pipeline, error) {
+	pipeline := mongo.Pipeline{}
+	if opts == nil {
+		return pipeline, nil
+	}
+	if opts.Filter != nil {
+		query, err := opts.Filter.GetDBQueryWithParser(p)
+		if err != nil {
+			return nil, err
+		}
+		match, ok := query.(bson.D)
+		if !ok {
+			return nil, newParserError("filter did not produce a query document")
+		}
+		if len(match) > 0 {
+			pipeline = append(pipeline, bson.D{{Key: "$match", Value: match}})
+		}
+	}
+	page := pageStages(opts)
+	if !opts.Count {
+		return append(pipeline, page...), nil
+	}
+	if len(page) == 0 {
+		// $facet doesn't allow empty sub-pipelines
+		page = mongo.Pipeline{{{Key: "$match", Value: bson.D{}}}}
+	}
+	return append(pipeline,
+		bson.D{{Key: "$facet", Value: bson.D{
+			{Key: "items", Value: page},
+			{Key: "count", Value: mongo.Pipeline{{{Key: "$count", Value: "count"}}}},
+		}}},
+		// $count produces no document at all when nothing matched
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "items", Value: 1},
+			{Key: "count", Value: bson.D{{Key: "$ifNull", Value: bson.A{bson.D{{Key: "$arrayElemAt", Value: bson.A{"$count.count", 0}}}, 0}}}},
+		}}},
+	), nil
+}
+
+func pageStages(opts *odata.QueryOptions) mongo.Pipeline {
+	stages := mongo.Pipeline{}
+	if opts.OrderBy != nil && len(opts.OrderBy.OrderItem) > 0 {
+		stages = append(stages, bson.D{{Key: "$sort", Value: sortDocument(opts.OrderBy.OrderItem)}})
+	}
+	if opts.Skip > 0 {
+		stages = append(stages, bson.D{{Key: "$skip", Value: opts.Skip}})
+	}
+	switch {
+	case opts.Top == 0:
+		stages = append(stages, bson.D{{Key: "$match", Value: falseQuery()}})
+	case opts.Top > 0:
+		stages = append(stages, bson.D{{Key: "$limit", Value: opts.Top}})
+	}
+	if opts.Select != nil {
+		if project := projectDocument(*opts.Select); project != nil {
+			stages = append(stages, bson.D{{Key: "$project", Value: project}})
+		}
+	}
+	return stages
+}
+
+// sortDocument builds the $sort stage, _id is added as a final key so that paging is stable.
+func sortDocument(items []orderby.OrderItem) bson.D {
+	sort := make(bson.D, 0, len(items)+1)
+	hasID := false
+	for _, item := range items {
+		field := fieldName(item.Property)
+		if field == "_id" {
+			hasID = true
+		}
+		direction := 1
+		if item.Direction == orderby.DESC {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: field, Value: direction})
+	}
+	if !hasID {
+		sort = append(sort, bson.E{Key: "_id", Value: 1})
+	}
+	return sort
+}
+
+// projectDocument builds the $project stage, nil means every field was selected. Like $select, _id is only returned
+// when it's asked for.
+func projectDocument(selects []string) bson.D {
+	project := make(bson.D, 0, len(selects)+1)
+	hasID := false
+	for _, property := range selects {
+		field := fieldName(property)
+		switch field {
+		case "*":
+			return nil
+		case "_id":
+			hasID = true
+		}
+		project = append(project, bson.E{Key: field, Value: 1})
+	}
+	if !hasID {
+		project = append(project, bson.E{Key: "_id", Value: 0})
+	}
+	return project
+}
+
+func fieldName(property string) string {
+	return strings.ReplaceAll(strings.TrimSpace(property), "/", ".")
+}
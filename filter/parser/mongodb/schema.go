@@ -0,0 +1,183 @@
+package mongodb
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pboyd04/godata/filter/lexer"
+	"github.com/pboyd04/godata/filter/parser"
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FieldType is the BSON type a field is stored as when it's not the type a filter literal would naturally become.
+type FieldType int
+
+const (
+	ObjectID FieldType = iota + 1
+	DateTime
+	Decimal128
+	UUID
+)
+
+func (t FieldType) String() string {
+	switch t {
+	case ObjectID:
+		return "ObjectID"
+	case DateTime:
+		return "DateTime"
+	case Decimal128:
+		return "Decimal128"
+	case UUID:
+		return "UUID"
+	default:
+		return "FieldType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// Schema maps fields to their BSON types. Keys are the MongoDB field paths, i.e. owner.id for Owner/Id.
+type Schema map[string]FieldType
+
+// DefaultSchema is the schema used by the registered "mongodb" parser, _id is an ObjectID.
+func DefaultSchema() Schema {
+	return Schema{"_id": ObjectID}
+}
+
+// coerceOperands converts the value side of a comparison to the type of the field it's compared to.
+func (p *Parser) coerceOperands(operator parser.Operator, operands []interface{}) error {
+	if len(p.schema) == 0 || len(operands) < 2 {
+		return nil
+	}
+	//nolint:exhaustive // Only comparisons are coerced
+	switch operator {
+	case lexer.Equals, lexer.NotEquals, lexer.GreaterThan, lexer.GreaterThanOrEqual, lexer.LessThan,
+		lexer.LessThanOrEqual, lexer.In:
+	default:
+		return nil
+	}
+	field, ok := operands[0].(string)
+	if !ok {
+		return nil
+	}
+	fieldType, ok := p.schema[field]
+	if !ok {
+		return nil
+	}
+	if list, ok := operands[1].([]interface{}); ok {
+		coerced := make([]interface{}, len(list))
+		for i, value := range list {
+			var err error
+			coerced[i], err = coerceValue(field, fieldType, value)
+			if err != nil {
+				return err
+			}
+		}
+		operands[1] = coerced
+		return nil
+	}
+	var err error
+	operands[1], err = coerceValue(field, fieldType, operands[1])
+	return err
+}
+
+func coerceValue(field string, fieldType FieldType, value interface{}) (interface{}, error) {
+	if str, ok := value.(string); value == nil || ok && isPlaceholder(str) {
+		// Placeholders are coerced once they've been replaced
+		return value, nil
+	}
+	var ret interface{}
+	var err error
+	switch fieldType {
+	case ObjectID:
+		ret, err = toObjectID(value)
+	case DateTime:
+		ret, err = toDateTime(value)
+	case Decimal128:
+		ret, err = toDecimal128(value)
+	case UUID:
+		ret, err = toUUID(value)
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return nil, &InvalidFieldValueError{Field: field, Type: fieldType, Value: value, Err: err}
+	}
+	return ret, nil
+}
+
+func isPlaceholder(value string) bool {
+	return len(value) > 1 && value[0] == ':'
+}
+
+func toObjectID(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case primitive.ObjectID:
+		return v, nil
+	case string:
+		return primitive.ObjectIDFromHex(v)
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+}
+
+func toDateTime(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+}
+
+func toDecimal128(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case primitive.Decimal128:
+		return v, nil
+	case int:
+		return primitive.ParseDecimal128(strconv.Itoa(v))
+	case int64:
+		return primitive.ParseDecimal128(strconv.FormatInt(v, 10))
+	case float64:
+		return primitive.ParseDecimal128(strconv.FormatFloat(v, 'f', -1, 64))
+	case decimal.Decimal:
+		return primitive.ParseDecimal128(v.String())
+	case string:
+		return primitive.ParseDecimal128(v)
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+}
+
+const (
+	uuidLength = 16
+	// bsonTypeUUID is the binary subtype for RFC 4122 UUIDs.
+	bsonTypeUUID = 0x04
+)
+
+func toUUID(value interface{}) (interface{}, error) {
+	var data []byte
+	switch v := value.(type) {
+	case primitive.Binary:
+		return v, nil
+	case [uuidLength]byte:
+		data = v[:]
+	case []byte:
+		data = v
+	case string:
+		var err error
+		data, err = hex.DecodeString(strings.ReplaceAll(v, "-", ""))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+	if len(data) != uuidLength {
+		return nil, ErrInvalidUUID
+	}
+	return primitive.Binary{Subtype: bsonTypeUUID, Data: data}, nil
+}
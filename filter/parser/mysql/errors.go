@@ -3,6 +3,8 @@ package mysql
 import (
 	"fmt"
 
+	"github.com/pboyd04/godata/errcode"
+	"github.com/pboyd04/godata/filter/lexer"
 	"github.com/pboyd04/godata/filter/parser"
 )
 
@@ -41,3 +43,19 @@ func (e *ParserError) Error() string {
 func newParserError(message string) error {
 	return &ParserError{message: message}
 }
+
+func newOperandCountError(operator parser.Operator, count int) error {
+	return newParserError(fmt.Sprintf("%s requires %d operands", lexer.TokenKey(operator), count))
+}
+
+func (e *UnsupportedOperandError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
+
+func (e *UnsupportedOperatorError) ErrorCode() errcode.Code {
+	return errcode.UnsupportedFunction
+}
+
+func (e *ParserError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
@@ -2,9 +2,10 @@ package mysql
 
 import (
 	"encoding/json"
-	"regexp"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pboyd04/godata/filter/lexer"
 	"github.com/pboyd04/godata/filter/parser"
@@ -12,17 +13,65 @@ import (
 
 const badString = "ERR! NOT A STRING"
 
+// dateTimeFormat is the format of a MySQL DATETIME literal.
+const dateTimeFormat = "2006-01-02 15:04:05.999999"
+
+// PathStyle controls how property paths such as Address/City are turned into SQL.
+type PathStyle int
+
+const (
+	// JoinAlias treats everything but the last segment as the alias of a joined table, i.e. `Address`.`City`.
+	JoinAlias PathStyle = iota
+	// JSONPath treats the first segment as a JSON column and the rest as a path inside it, i.e. `Address`->>'$.City'.
+	JSONPath
+)
+
+// sqlFunction is a MySQL expression for an OData function, the template has a %s for each operand.
+type sqlFunction struct {
+	template string
+	operands int
+}
+
+//nolint:gochecknoglobals // This is a constant lookup table
+var sqlFunctions = map[parser.Operator]sqlFunction{
+	lexer.Concat:            {"CONCAT(%s,%s)", 2},
+	lexer.IndexOf:           {"(LOCATE(%[2]s,%[1]s)-1)", 2},
+	lexer.ToLower:           {"LOWER(%s)", 1},
+	lexer.ToUpper:           {"UPPER(%s)", 1},
+	lexer.Trim:              {"TRIM(%s)", 1},
+	lexer.Year:              {"EXTRACT(YEAR FROM %s)", 1},
+	lexer.Month:             {"EXTRACT(MONTH FROM %s)", 1},
+	lexer.Day:               {"EXTRACT(DAY FROM %s)", 1},
+	lexer.Hour:              {"EXTRACT(HOUR FROM %s)", 1},
+	lexer.Minute:            {"EXTRACT(MINUTE FROM %s)", 1},
+	lexer.Second:            {"EXTRACT(SECOND FROM %s)", 1},
+	lexer.FractionalSeconds: {"(EXTRACT(MICROSECOND FROM %s)/1000)", 1},
+	lexer.Round:             {"ROUND(%s)", 1},
+	lexer.Floor:             {"FLOOR(%s)", 1},
+	lexer.Ceiling:           {"CEILING(%s)", 1},
+}
+
 type Parser struct {
-	functionMatch       *regexp.Regexp
-	alreadyEscapedMatch *regexp.Regexp
+	pathStyle PathStyle
+	aliases   map[string]string
 }
 
+// sqlExpr is SQL the parser has written, a quoted column or an operation, where a string is a literal to be quoted.
+type sqlExpr string
+
 func init() {
 	// Register the parser
-	parser.RegisterParser("mysql", &Parser{
-		functionMatch:       regexp.MustCompile(`[A-Z]+[(]`),
-		alreadyEscapedMatch: regexp.MustCompile(`\x60(\w)+\x60`),
-	})
+	parser.RegisterParser("mysql", NewParser(JoinAlias, nil))
+}
+
+// NewParser creates a MySQL parser with a custom property path style. For JoinAlias the aliases map the navigation
+// part of a path (i.e. Customer/Address) to the alias used in the query, by default the segments are joined with _.
+// Register the result with parser.RegisterParser to use it.
+func NewParser(pathStyle PathStyle, aliases map[string]string) *Parser {
+	return &Parser{
+		pathStyle: pathStyle,
+		aliases:   aliases,
+	}
 }
 
 func (p *Parser) GetDBQuery(common *parser.Parser) (interface{}, error) {
@@ -47,10 +96,25 @@ func (p *Parser) GetDBQueryWithReplacement(common *parser.Parser, a ...interface
 
 //nolint:funlen,cyclop
 func (p *Parser) getMySQLQuery(op *parser.Operation) (string, error) {
+	//nolint:exhaustive // Only the operators that need the raw operands
+	switch op.Operator {
+	case lexer.Case:
+		return p.doCase(op)
+	case lexer.Substring:
+		return p.doSubstring(op)
+	case lexer.MatchesPattern:
+		return p.doMatchesPattern(op)
+	}
+	if fn, ok := sqlFunctions[op.Operator]; ok {
+		return p.doFunction(op, fn)
+	}
 	operands, err := p.getMySQLOperands(op.Operands)
 	if err != nil {
 		return "", err
 	}
+	if test, other := parser.CompareNull(op.Operator, operands); test != parser.NoNullTest {
+		return p.doNullTest(test, other), nil
+	}
 	//nolint:exhaustive // This won't cover everything and will use the default case to catch errors
 	switch op.Operator {
 	case lexer.TokenTrue:
@@ -58,19 +122,19 @@ func (p *Parser) getMySQLQuery(op *parser.Operation) (string, error) {
 	case lexer.TokenFalse:
 		return "1=0", nil
 	case lexer.Equals:
-		return p.escapeColName(operands[0]) + "=" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + "=" + escapeValue(operands[1]), nil
 	case lexer.NotEquals:
-		return p.escapeColName(operands[0]) + "!=" + escapeValue(operands[1]), nil
+		return doNotEquals(operands[0], operands[1]), nil
 	case lexer.GreaterThan:
-		return p.escapeColName(operands[0]) + ">" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + ">" + escapeValue(operands[1]), nil
 	case lexer.GreaterThanOrEqual:
-		return p.escapeColName(operands[0]) + ">=" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + ">=" + escapeValue(operands[1]), nil
 	case lexer.LessThan:
-		return p.escapeColName(operands[0]) + "<" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + "<" + escapeValue(operands[1]), nil
 	case lexer.LessThanOrEqual:
-		return p.escapeColName(operands[0]) + "<=" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + "<=" + escapeValue(operands[1]), nil
 	case lexer.In:
-		return p.escapeColName(operands[0]) + " IN " + escapeValue(operands[1]), nil
+		return p.doIn(operands[0], operands[1]), nil
 	case lexer.And, lexer.Or:
 		return p.doCombination(op.Operator, operands[0], operands[1])
 	case lexer.StartsWith:
@@ -80,28 +144,35 @@ func (p *Parser) getMySQLQuery(op *parser.Operation) (string, error) {
 	case lexer.Contains:
 		return p.doRegex("%", "%", operands[0], operands[1])
 	case lexer.Not:
-		return insertNotOp(operands[0])
+		return p.doNot(op.Operands[0], operands[0])
 	case lexer.Length:
-		return "LENGTH(" + p.escapeColName(operands[0]) + ")", nil
+		// LENGTH counts bytes, OData's length counts characters
+		return "CHAR_LENGTH(" + escapeValue(operands[0]) + ")", nil
 	case lexer.HasSubset:
-		return "JSON_CONTAINS(" + p.escapeColName(operands[0]) + ",'" + escapeJSONValue(operands[1]) + "')", nil
+		jsonValue, err := quoteJSON(operands[1])
+		if err != nil {
+			return "", err
+		}
+		return "JSON_CONTAINS(" + escapeValue(operands[0]) + "," + jsonValue + ")", nil
+	case lexer.HasSubsequence:
+		return p.doHasSubsequence(operands[0], operands[1])
 	case lexer.Add:
-		return p.escapeColName(operands[0]) + "+" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + "+" + escapeValue(operands[1]), nil
 	case lexer.Subtract:
-		return p.escapeColName(operands[0]) + "-" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + "-" + escapeValue(operands[1]), nil
 	case lexer.Multiply:
-		return p.escapeColName(operands[0]) + "*" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + "*" + escapeValue(operands[1]), nil
 	case lexer.Divide:
 		_, ok := operands[1].(int)
 		if ok {
 			// This is an integer, so I need to use the DIV operator per odata spec
-			return p.escapeColName(operands[0]) + " DIV " + escapeValue(operands[1]), nil
+			return escapeValue(operands[0]) + " DIV " + escapeValue(operands[1]), nil
 		}
-		return p.escapeColName(operands[0]) + "/" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + "/" + escapeValue(operands[1]), nil
 	case lexer.DivideFloat:
-		return p.escapeColName(operands[0]) + "/" + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + "/" + escapeValue(operands[1]), nil
 	case lexer.Modulo:
-		return p.escapeColName(operands[0]) + " MOD " + escapeValue(operands[1]), nil
+		return escapeValue(operands[0]) + " MOD " + escapeValue(operands[1]), nil
 	default:
 		return "", newUnsupportedOperatorError(op.Operator)
 	}
@@ -112,15 +183,178 @@ func (p *Parser) doCombination(op parser.Operator, operand0, operand1 interface{
 	if op == lexer.Or {
 		comb = " OR "
 	}
-	strOp0, ok := operand0.(string)
+	strOp0, ok := operand0.(sqlExpr)
 	if !ok {
 		return "", newParserError("attempting to combine a non-string op0")
 	}
-	strOp1, ok := operand1.(string)
+	strOp1, ok := operand1.(sqlExpr)
 	if !ok {
 		return "", newParserError("attempting to combine a non-string op1")
 	}
-	return strOp0 + comb + strOp1, nil
+	return string(strOp0) + comb + string(strOp1), nil
+}
+
+func (p *Parser) doCase(op *parser.Operation) (string, error) {
+	ret := "CASE"
+	for i := 0; i+1 < len(op.Operands); i += 2 {
+		value, err := p.exprOperand(op.Operands[i+1])
+		if err != nil {
+			return "", err
+		}
+		token, ok := op.Operands[i].(*lexer.Token)
+		if ok && token.Type == lexer.TokenTrue {
+			// true is the catch all branch, anything after it can never be reached
+			if i == 0 {
+				return value, nil
+			}
+			ret += " ELSE " + value
+			break
+		}
+		cond, err := p.exprOperand(op.Operands[i])
+		if err != nil {
+			return "", err
+		}
+		ret += " WHEN " + cond + " THEN " + value
+	}
+	return ret + " END", nil
+}
+
+// exprOperand renders a case() condition or value or a function argument. Unlike the comparison operators these can be
+// either literals or columns in any position.
+func (p *Parser) exprOperand(operand parser.Operand) (string, error) {
+	switch data := operand.(type) {
+	case *parser.PropertyPath:
+		return p.escapePath(data), nil
+	case *lexer.Token:
+		switch data.Type {
+		case lexer.UnquotedString:
+			return quoteIdentifier(data.Text), nil
+		case lexer.TokenFalse:
+			return "1=0", nil
+		default:
+			value, err := data.GetData()
+			if err != nil {
+				return "", err
+			}
+			return escapeValue(value), nil
+		}
+	case *parser.Operation:
+		return p.getMySQLQuery(data)
+	default:
+		return "", newUnsupportedOperandError(data)
+	}
+}
+
+func (p *Parser) doFunction(op *parser.Operation, fn sqlFunction) (string, error) {
+	if len(op.Operands) != fn.operands {
+		return "", newOperandCountError(op.Operator, fn.operands)
+	}
+	args := make([]interface{}, len(op.Operands))
+	for i, operand := range op.Operands {
+		arg, err := p.exprOperand(operand)
+		if err != nil {
+			return "", err
+		}
+		args[i] = arg
+	}
+	return fmt.Sprintf(fn.template, args...), nil
+}
+
+// doSubstring converts OData's zero based index to MySQL's one based one.
+func (p *Parser) doSubstring(op *parser.Operation) (string, error) {
+	if len(op.Operands) != 2 && len(op.Operands) != 3 {
+		return "", newOperandCountError(lexer.Substring, 2)
+	}
+	str, err := p.exprOperand(op.Operands[0])
+	if err != nil {
+		return "", err
+	}
+	start, err := p.exprOperand(op.Operands[1])
+	if err != nil {
+		return "", err
+	}
+	if index, ok := intLiteral(op.Operands[1]); ok {
+		start = strconv.Itoa(index + 1)
+	} else {
+		start = "(" + start + ")+1"
+	}
+	if len(op.Operands) == 2 {
+		return "SUBSTRING(" + str + "," + start + ")", nil
+	}
+	length, err := p.exprOperand(op.Operands[2])
+	if err != nil {
+		return "", err
+	}
+	return "SUBSTRING(" + str + "," + start + "," + length + ")", nil
+}
+
+// doMatchesPattern uses a case sensitive REGEXP_LIKE, a literal pattern is quoted like any other string.
+func (p *Parser) doMatchesPattern(op *parser.Operation) (string, error) {
+	if len(op.Operands) != 2 {
+		return "", newOperandCountError(lexer.MatchesPattern, 2)
+	}
+	str, err := p.exprOperand(op.Operands[0])
+	if err != nil {
+		return "", err
+	}
+	var pattern string
+	if data, err := op.Operands[1].GetData(); err == nil {
+		if s, ok := data.(string); ok && isQuoted(op.Operands[1]) {
+			pattern = quoteString(s)
+		}
+	}
+	if pattern == "" {
+		pattern, err = p.exprOperand(op.Operands[1])
+		if err != nil {
+			return "", err
+		}
+	}
+	return "REGEXP_LIKE(" + str + "," + pattern + ",'c')", nil
+}
+
+// doHasSubsequence joins one JSON_TABLE per value and requires their positions in the array to be increasing.
+func (p *Parser) doHasSubsequence(operand0, operand1 interface{}) (string, error) {
+	values, ok := operand1.([]interface{})
+	if !ok {
+		return "", newUnsupportedOperandError(operand1)
+	}
+	if len(values) == 0 {
+		return "1=1", nil
+	}
+	table := "JSON_TABLE(" + escapeValue(operand0) + ",'$[*]' COLUMNS(i FOR ORDINALITY,v JSON PATH '$'))"
+	tables := make([]string, len(values))
+	conds := make([]string, 0, 2*len(values)-1)
+	for i, value := range values {
+		jsonValue, err := quoteJSON(value)
+		if err != nil {
+			return "", err
+		}
+		alias := "s" + strconv.Itoa(i)
+		tables[i] = table + " AS " + alias
+		conds = append(conds, alias+".v=CAST("+jsonValue+" AS JSON)")
+		if i > 0 {
+			conds = append(conds, "s"+strconv.Itoa(i-1)+".i<"+alias+".i")
+		}
+	}
+	return "EXISTS(SELECT 1 FROM " + strings.Join(tables, ",") + " WHERE " + strings.Join(conds, " AND ") + ")", nil
+}
+
+func intLiteral(operand parser.Operand) (int, bool) {
+	token, ok := operand.(*lexer.Token)
+	if !ok || token.Type != lexer.IntegerLiteral {
+		return 0, false
+	}
+	data, err := token.GetData()
+	if err != nil {
+		return 0, false
+	}
+	i, ok := data.(int)
+	return i, ok
+}
+
+func isQuoted(operand parser.Operand) bool {
+	token, ok := operand.(*lexer.Token)
+	return ok && (token.Type == lexer.SingleQuotedString || token.Type == lexer.DoubleQuotedString)
 }
 
 func (p *Parser) doRegex(prefix, postfix string, operand0, operand1 interface{}) (string, error) {
@@ -128,7 +362,7 @@ func (p *Parser) doRegex(prefix, postfix string, operand0, operand1 interface{})
 	if !ok {
 		return "", newParserError("attempting to do a regex with a non-string value")
 	}
-	return p.escapeColName(operand0) + " LIKE '" + prefix + strOp1 + postfix + "'", nil
+	return escapeValue(operand0) + " LIKE " + quoteString(prefix+parser.EscapeLike(strOp1)+postfix) + " ESCAPE '" + parser.LikeEscape + "'", nil
 }
 
 func (p *Parser) getMySQLOperands(operands []parser.Operand) ([]interface{}, error) {
@@ -144,19 +378,24 @@ func (p *Parser) getMySQLOperands(operands []parser.Operand) ([]interface{}, err
 }
 
 func (p *Parser) getMySQLOperand(operand parser.Operand) (interface{}, error) {
+	if token, ok := operand.(*lexer.Token); ok && token.Type == lexer.UnquotedString {
+		return sqlExpr(quoteIdentifier(token.Text)), nil
+	}
 	data, err := operand.GetData()
 	if err != nil {
 		return nil, err
 	}
 	switch op := data.(type) {
-	case string, float64, int, map[string]interface{}:
+	case string, float64, int, bool, time.Time, map[string]interface{}, nil:
 		return op, nil
+	case *parser.PropertyPath:
+		return sqlExpr(p.escapePath(op)), nil
 	case *parser.Operation:
 		inner, err := p.getMySQLQuery(op)
 		if err != nil {
 			return nil, err
 		}
-		return inner, nil
+		return sqlExpr(inner), nil
 	case []parser.Operand:
 		tmp := make([]interface{}, 0)
 		for _, o := range op {
@@ -176,43 +415,119 @@ func (p *Parser) getMySQLOperand(operand parser.Operand) (interface{}, error) {
 	}
 }
 
-func insertNotOp(s interface{}) (string, error) {
-	str, ok := s.(string)
+// doNot negates a condition, a condition that can be null is made false first so that not keeps the rows OData does.
+func (p *Parser) doNot(operand parser.Operand, s interface{}) (string, error) {
+	str, ok := s.(sqlExpr)
 	if !ok {
 		return "", newUnsupportedOperandError(s)
 	}
-	parts := strings.Split(str, " ")
-	if len(parts) >= 2 {
-		return parts[0] + " NOT " + strings.Join(parts[1:], " "), nil
+	if parser.NullSafeNot(operand) {
+		return "NOT (" + string(str) + ")", nil
 	}
-	return "NOT " + str, nil
+	return "NOT COALESCE(" + string(str) + ",FALSE)", nil
 }
 
-func (p *Parser) escapeColName(s interface{}) string {
-	switch data := s.(type) {
-	case string:
-		if p.functionMatch.MatchString(data) || p.alreadyEscapedMatch.MatchString(data) {
-			// We don't need to escape function names
-			return data
-		}
-		return "`" + data + "`"
-	case float64:
-		return strconv.FormatFloat(data, 'f', -1, 64)
-	case int:
-		return strconv.Itoa(data)
+func (p *Parser) doNullTest(test parser.NullTest, other interface{}) string {
+	//nolint:exhaustive // NoNullTest never gets here
+	switch test {
+	case parser.IsNull:
+		return escapeValue(other) + " IS NULL"
+	case parser.IsNotNull:
+		return escapeValue(other) + " IS NOT NULL"
+	case parser.AlwaysTrue:
+		return "1=1"
 	default:
-		return badString
+		return "1=0"
+	}
+}
+
+// doNotEquals makes ne true when only one side is null, OData's ne is <=> negated where SQL's != is null.
+func doNotEquals(operand0, operand1 interface{}) string {
+	_, expr0 := operand0.(sqlExpr)
+	_, expr1 := operand1.(sqlExpr)
+	lhs, rhs := escapeValue(operand0), escapeValue(operand1)
+	switch {
+	case expr0 && expr1:
+		return "NOT (" + lhs + "<=>" + rhs + ")"
+	case expr0:
+		return "(" + lhs + "!=" + rhs + " OR " + lhs + " IS NULL)"
+	case expr1:
+		return "(" + lhs + "!=" + rhs + " OR " + rhs + " IS NULL)"
+	default:
+		return lhs + "!=" + rhs
+	}
+}
+
+// doIn matches a null in the list with IS NULL, SQL's IN never matches null.
+func (p *Parser) doIn(operand0, operand1 interface{}) string {
+	list, ok := operand1.([]interface{})
+	if !ok {
+		return escapeValue(operand0) + " IN " + escapeValue(operand1)
+	}
+	values, hasNull := parser.SplitNull(list)
+	switch {
+	case !hasNull:
+		return escapeValue(operand0) + " IN " + escapeValue(values)
+	case len(values) == 0:
+		return escapeValue(operand0) + " IS NULL"
+	default:
+		return "(" + escapeValue(operand0) + " IN " + escapeValue(values) + " OR " + escapeValue(operand0) + " IS NULL)"
 	}
 }
 
+func (p *Parser) escapePath(path *parser.PropertyPath) string {
+	last := len(path.Segments) - 1
+	if p.pathStyle == JSONPath {
+		return quoteIdentifier(path.Segments[0]) + "->>" + quoteString("$."+strings.Join(path.Segments[1:], "."))
+	}
+	navigation := strings.Join(path.Segments[:last], "/")
+	alias, ok := p.aliases[navigation]
+	if !ok {
+		alias = strings.Join(path.Segments[:last], "_")
+	}
+	return quoteIdentifier(alias) + "." + quoteIdentifier(path.Segments[last])
+}
+
+// quoteIdentifier writes name as a MySQL quoted identifier.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+//nolint:gochecknoglobals // The replacer is safe for concurrent use and only needs to be built once
+var stringEscaper = strings.NewReplacer(`\`, `\\`, "'", "''", "\x00", `\0`, "\n", `\n`, "\r", `\r`, "\x1a", `\Z`)
+
+// quoteString writes s as a MySQL string literal, escaping the same characters as mysql_real_escape_string so that
+// nothing in s can end the literal.
+func quoteString(s string) string {
+	return "'" + stringEscaper.Replace(s) + "'"
+}
+
+// quoteJSON writes value as a MySQL string literal holding its JSON, for the JSON functions.
+func quoteJSON(value interface{}) (string, error) {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return quoteString(string(jsonData)), nil
+}
+
+// escapeValue writes an operand as SQL, literals are quoted and SQL the parser wrote is used as it is.
 func escapeValue(s interface{}) string {
 	switch data := s.(type) {
+	case sqlExpr:
+		return string(data)
+	case nil:
+		return "NULL"
 	case string:
-		return "'" + data + "'"
+		return quoteString(data)
 	case float64:
 		return strconv.FormatFloat(data, 'f', -1, 64)
 	case int:
 		return strconv.Itoa(data)
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(data))
+	case time.Time:
+		return "'" + data.UTC().Format(dateTimeFormat) + "'"
 	case []interface{}:
 		ret := "("
 		for i, v := range data {
@@ -226,30 +541,7 @@ func escapeValue(s interface{}) string {
 	case map[string]interface{}:
 		//nolint:errchkjson // This was unmarshaled from JSON, so it should be valid
 		jsonData, _ := json.Marshal(data)
-		return "'" + strings.ReplaceAll(string(jsonData), `"`, `\"`) + "'"
-	default:
-		return badString
-	}
-}
-
-func escapeJSONValue(s interface{}) string {
-	switch data := s.(type) {
-	case string:
-		return `"` + data + `"`
-	case float64:
-		return strconv.FormatFloat(data, 'f', -1, 64)
-	case int:
-		return strconv.Itoa(data)
-	case []interface{}:
-		ret := "["
-		for i, v := range data {
-			ret += escapeJSONValue(v)
-			if i != len(data)-1 {
-				ret += ","
-			}
-		}
-		ret += "]"
-		return ret
+		return quoteString(string(jsonData))
 	default:
 		return badString
 	}
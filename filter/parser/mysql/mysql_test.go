@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/pboyd04/godata/filter/parser"
+	"github.com/pboyd04/godata/filter/parser/mysql"
 )
 
 type testData struct {
@@ -31,7 +32,44 @@ var testCases = []testData{
 	},
 	{
 		input:           "Name ne 'Milk'",
-		expectedSQLText: "`Name`!='Milk'",
+		expectedSQLText: "(`Name`!='Milk' OR `Name` IS NULL)",
+	},
+	{
+		input:           "'Milk' ne Name",
+		expectedSQLText: "('Milk'!=`Name` OR `Name` IS NULL)",
+	},
+	{
+		input:           "Name ne Brand",
+		expectedSQLText: "NOT (`Name`<=>`Brand`)",
+	},
+	{
+		input:           "BirthDate gt 2020-01-01",
+		expectedSQLText: "`BirthDate`>'2020-01-01'",
+	},
+	{
+		input:           "Created lt 2020-01-01T10:30:00+02:00",
+		expectedSQLText: "`Created`<'2020-01-01 08:30:00'",
+	},
+	{
+		input:           "Id eq 01234567-89ab-cdef-0123-456789abcdef",
+		expectedSQLText: "`Id`='01234567-89ab-cdef-0123-456789abcdef'",
+	},
+	{
+		input:           "Price gt Cost",
+		expectedSQLText: "`Price`>`Cost`",
+	},
+	{
+		// Strings that look like SQL are still quoted, wherever they are
+		input:           "'DROP(1) OR 1=1' eq Name",
+		expectedSQLText: "'DROP(1) OR 1=1'=`Name`",
+	},
+	{
+		input:           "'`a`' eq Name",
+		expectedSQLText: "'`a`'=`Name`",
+	},
+	{
+		input:           "length('CASE WHEN 1=1 THEN 1 END') eq 1",
+		expectedSQLText: "CHAR_LENGTH('CASE WHEN 1=1 THEN 1 END')=1",
 	},
 	{
 		input:           "Name gt 'Milk'",
@@ -65,6 +103,10 @@ var testCases = []testData{
 		input:           "Name eq 'Milk' or Price lt 2.55",
 		expectedSQLText: "`Name`='Milk' OR `Price`<2.55",
 	},
+	{
+		input:           "order eq'Milk' or trueCount lt 2",
+		expectedSQLText: "`order`='Milk' OR `trueCount`<2",
+	},
 	{
 		input:           "Name in ('Milk', 'Cheese')",
 		expectedSQLText: "`Name` IN ('Milk','Cheese')",
@@ -75,32 +117,64 @@ var testCases = []testData{
 	},
 	{
 		input:           "contains(Name,'red')",
-		expectedSQLText: "`Name` LIKE '%red%'",
+		expectedSQLText: "`Name` LIKE '%red%' ESCAPE '!'",
 	},
 	{
 		input:           `Address eq {"Street":"NE 40th","City":"Redmond","State":"WA","ZipCode":"98052"}`,
-		expectedSQLText: "`Address`='{\\\"City\\\":\\\"Redmond\\\",\\\"State\\\":\\\"WA\\\",\\\"Street\\\":\\\"NE 40th\\\",\\\"ZipCode\\\":\\\"98052\\\"}'",
+		expectedSQLText: "`Address`='{\"City\":\"Redmond\",\"State\":\"WA\",\"Street\":\"NE 40th\",\"ZipCode\":\"98052\"}'",
+	},
+	{
+		input:           `Address eq {"Street":"O'Neil's Way","Note":"a\\b"}`,
+		expectedSQLText: "`Address`='{\"Note\":\"a\\\\\\\\b\",\"Street\":\"O''Neil''s Way\"}'",
+	},
+	{
+		input:           `Name eq 'O''Neil'`,
+		expectedSQLText: "`Name`='O''Neil'",
+	},
+	{
+		input:           `Şehir eq 'İstanbul' or 名前 eq '東京'`,
+		expectedSQLText: "`Şehir`='İstanbul' OR `名前`='東京'",
+	},
+	{
+		input:           `Name eq 'C:\Temp' or Name eq 'x'' OR 1=1 -- '`,
+		expectedSQLText: "`Name`='C:\\\\Temp' OR `Name`='x'' OR 1=1 -- '",
+	},
+	{
+		input:           `contains(Name,'O''Neil')`,
+		expectedSQLText: "`Name` LIKE '%O''Neil%' ESCAPE '!'",
+	},
+	{
+		input:           "contains(Name,'50%_off!')",
+		expectedSQLText: "`Name` LIKE '%50!%!_off!!%' ESCAPE '!'",
 	},
 	{
 		input:           "endswith(Name,'ilk')",
-		expectedSQLText: "`Name` LIKE '%ilk'",
+		expectedSQLText: "`Name` LIKE '%ilk' ESCAPE '!'",
 	},
 	{
 		input:           "not endswith(Name,'ilk')",
-		expectedSQLText: "`Name` NOT LIKE '%ilk'",
+		expectedSQLText: "NOT COALESCE(`Name` LIKE '%ilk' ESCAPE '!',FALSE)",
 	},
 	{
 		input:           "length(CompanyName) eq 19",
-		expectedSQLText: "LENGTH(`CompanyName`)=19",
+		expectedSQLText: "CHAR_LENGTH(`CompanyName`)=19",
 	},
 	{
 		input:           "startswith(CompanyName,'Futterkiste')",
-		expectedSQLText: "`CompanyName` LIKE 'Futterkiste%'",
+		expectedSQLText: "`CompanyName` LIKE 'Futterkiste%' ESCAPE '!'",
 	},
 	{
 		input:           `hassubset(Names,["Milk", "Cheese"])`,
 		expectedSQLText: "JSON_CONTAINS(`Names`,'[\"Milk\",\"Cheese\"]')", // This is mysql syntax. Don't copy to other SQL parsers
 	},
+	{
+		input:           `hassubset(Names,['x'') OR 1=1 -- ',"a\b",'say "hi"'])`,
+		expectedSQLText: "JSON_CONTAINS(`Names`,'[\"x'') OR 1=1 -- \",\"a\\\\\\\\b\",\"say \\\\\"hi\\\\\"\"]')",
+	},
+	{
+		input:           `hassubset(Names,["a' OR 1=1 -- "])`,
+		expectedSQLText: "JSON_CONTAINS(`Names`,'[\"a'' OR 1=1 -- \"]')",
+	},
 	{
 		input:           `Price add 2.45 eq 5.00`,
 		expectedSQLText: "`Price`+2.45=5",
@@ -129,6 +203,132 @@ var testCases = []testData{
 		input:           `Rating mod 5 eq 0`,
 		expectedSQLText: "`Rating` MOD 5=0",
 	},
+	{
+		input:           `Address/City eq 'Redmond'`,
+		expectedSQLText: "`Address`.`City`='Redmond'",
+	},
+	{
+		input:           `startswith(Customer/Address/Street,'NE')`,
+		expectedSQLText: "`Customer_Address`.`Street` LIKE 'NE%' ESCAPE '!'",
+	},
+	{
+		input:           `concat(Name,'s') eq 'Milks'`,
+		expectedSQLText: "CONCAT(`Name`,'s')='Milks'",
+	},
+	{
+		input:           `concat('Brand ',Name) eq 'Brand Milk'`,
+		expectedSQLText: "CONCAT('Brand ',`Name`)='Brand Milk'",
+	},
+	{
+		input:           `indexof(Name,'ilk') eq 1`,
+		expectedSQLText: "(LOCATE('ilk',`Name`)-1)=1",
+	},
+	{
+		input:           `substring(Name,1) eq 'ilk'`,
+		expectedSQLText: "SUBSTRING(`Name`,2)='ilk'",
+	},
+	{
+		input:           `substring(Name,1,2) eq 'il'`,
+		expectedSQLText: "SUBSTRING(`Name`,2,2)='il'",
+	},
+	{
+		input:           `substring(Name,Offset) eq 'ilk'`,
+		expectedSQLText: "SUBSTRING(`Name`,(`Offset`)+1)='ilk'",
+	},
+	{
+		input:           `tolower(Name) eq 'milk'`,
+		expectedSQLText: "LOWER(`Name`)='milk'",
+	},
+	{
+		input:           `toupper(Name) eq 'MILK'`,
+		expectedSQLText: "UPPER(`Name`)='MILK'",
+	},
+	{
+		input:           `trim(Name) eq 'Milk'`,
+		expectedSQLText: "TRIM(`Name`)='Milk'",
+	},
+	{
+		input:           `length(trim(Name)) eq 4`,
+		expectedSQLText: "CHAR_LENGTH(TRIM(`Name`))=4",
+	},
+	{
+		input:           `matchesPattern(Name,'^M\w+$')`,
+		expectedSQLText: "REGEXP_LIKE(`Name`,'^M\\\\w+$','c')",
+	},
+	{
+		input:           `not matchesPattern(Name,'^M')`,
+		expectedSQLText: "NOT COALESCE(REGEXP_LIKE(`Name`,'^M','c'),FALSE)",
+	},
+	{
+		input:           `matchesPattern(CompanyName,'%5EA.*e$')`,
+		expectedSQLText: "REGEXP_LIKE(`CompanyName`,'%5EA.*e$','c')",
+	},
+	{
+		input: `hassubsequence(Names,['Milk','Cheese'])`,
+		expectedSQLText: "EXISTS(SELECT 1 FROM JSON_TABLE(`Names`,'$[*]' COLUMNS(i FOR ORDINALITY,v JSON PATH '$')) AS s0," +
+			"JSON_TABLE(`Names`,'$[*]' COLUMNS(i FOR ORDINALITY,v JSON PATH '$')) AS s1 " +
+			"WHERE s0.v=CAST('\"Milk\"' AS JSON) AND s1.v=CAST('\"Cheese\"' AS JSON) AND s0.i<s1.i)",
+	},
+	{
+		input: `hassubsequence(Names,['x'') OR 1=1 -- ',"a\b",'say "hi"'])`,
+		expectedSQLText: "EXISTS(SELECT 1 FROM JSON_TABLE(`Names`,'$[*]' COLUMNS(i FOR ORDINALITY,v JSON PATH '$')) AS s0," +
+			"JSON_TABLE(`Names`,'$[*]' COLUMNS(i FOR ORDINALITY,v JSON PATH '$')) AS s1," +
+			"JSON_TABLE(`Names`,'$[*]' COLUMNS(i FOR ORDINALITY,v JSON PATH '$')) AS s2 " +
+			"WHERE s0.v=CAST('\"x'') OR 1=1 -- \"' AS JSON) AND s1.v=CAST('\"a\\\\\\\\b\"' AS JSON) AND s0.i<s1.i " +
+			"AND s2.v=CAST('\"say \\\\\"hi\\\\\"\"' AS JSON) AND s1.i<s2.i)",
+	},
+	{
+		input:           `year(BirthDate) eq 2000`,
+		expectedSQLText: "EXTRACT(YEAR FROM `BirthDate`)=2000",
+	},
+	{
+		input:           `month(BirthDate) eq 5`,
+		expectedSQLText: "EXTRACT(MONTH FROM `BirthDate`)=5",
+	},
+	{
+		input:           `day(BirthDate) eq 8`,
+		expectedSQLText: "EXTRACT(DAY FROM `BirthDate`)=8",
+	},
+	{
+		input:           `hour(BirthDate) eq 13`,
+		expectedSQLText: "EXTRACT(HOUR FROM `BirthDate`)=13",
+	},
+	{
+		input:           `minute(BirthDate) eq 1`,
+		expectedSQLText: "EXTRACT(MINUTE FROM `BirthDate`)=1",
+	},
+	{
+		input:           `second(BirthDate) eq 59`,
+		expectedSQLText: "EXTRACT(SECOND FROM `BirthDate`)=59",
+	},
+	{
+		input:           `fractionalseconds(BirthDate) lt 0.1`,
+		expectedSQLText: "(EXTRACT(MICROSECOND FROM `BirthDate`)/1000)<0.1",
+	},
+	{
+		input:           `not (year(BirthDate) eq 2000)`,
+		expectedSQLText: "NOT COALESCE(EXTRACT(YEAR FROM `BirthDate`)=2000,FALSE)",
+	},
+	{
+		input:           `round(Price) eq 3`,
+		expectedSQLText: "ROUND(`Price`)=3",
+	},
+	{
+		input:           `floor(Price) eq 2`,
+		expectedSQLText: "FLOOR(`Price`)=2",
+	},
+	{
+		input:           `ceiling(Price) eq 3`,
+		expectedSQLText: "CEILING(`Price`)=3",
+	},
+	{
+		input:           `case(Status eq 'A':1, Status eq 'B':2, true:0) gt 0`,
+		expectedSQLText: "CASE WHEN `Status`='A' THEN 1 WHEN `Status`='B' THEN 2 ELSE 0 END>0",
+	},
+	{
+		input:           `case(Price gt 10:Price mul 2, true:Price) le 20`,
+		expectedSQLText: "CASE WHEN `Price`>10 THEN `Price`*2 ELSE `Price` END<=20",
+	},
 }
 
 func TestMySQL(t *testing.T) {
@@ -156,6 +356,30 @@ func TestMySQL(t *testing.T) {
 	}
 }
 
+func TestMySQLPathStyles(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		parser   *mysql.Parser
+		expected string
+	}{
+		{mysql.NewParser(mysql.JoinAlias, map[string]string{"Customer/Address": "addr"}), "`addr`.`City`='Redmond'"},
+		{mysql.NewParser(mysql.JSONPath, nil), "`Customer`->>'$.Address.City'='Redmond'"},
+	}
+	common, err := parser.NewParser("Customer/Address/City eq 'Redmond'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		res, err := test.parser.GetDBQuery(common)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, res)
+		}
+	}
+}
+
 func BenchmarkMySQL(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		for _, test := range testCases {
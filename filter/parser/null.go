@@ -0,0 +1,91 @@
+package parser
+
+import "github.com/pboyd04/godata/filter/lexer"
+
+// NullTest is what a comparison against null reduces to.
+type NullTest int
+
+const (
+	// NoNullTest means the comparison doesn't involve null and is translated as usual.
+	NoNullTest NullTest = iota
+	// IsNull is true when the other operand is null.
+	IsNull
+	// IsNotNull is true when the other operand isn't null.
+	IsNotNull
+	// AlwaysTrue is a comparison that is true for every row, i.e. null eq null.
+	AlwaysTrue
+	// AlwaysFalse is a comparison that is false for every row, i.e. Price gt null.
+	AlwaysFalse
+)
+
+// CompareNull works out a comparison where one or both of the resolved operands are null. Following OData, null is
+// equal to null and unordered against everything else, so eq and ne become null checks, gt and lt are never true and
+// ge and le are only true when both sides are null. The null can be on either side, the other operand is returned.
+func CompareNull(operator Operator, operands []interface{}) (NullTest, interface{}) {
+	if len(operands) != 2 || operands[0] != nil && operands[1] != nil {
+		return NoNullTest, nil
+	}
+	other := operands[0]
+	if other == nil {
+		other = operands[1]
+	}
+	//nolint:exhaustive // Only comparisons are affected
+	switch operator {
+	case lexer.Equals, lexer.GreaterThanOrEqual, lexer.LessThanOrEqual:
+		if other == nil {
+			return AlwaysTrue, nil
+		}
+		// For ge and le this is only true if the other side turns out to be null too
+		return IsNull, other
+	case lexer.NotEquals:
+		if other == nil {
+			return AlwaysFalse, nil
+		}
+		return IsNotNull, other
+	case lexer.GreaterThan, lexer.LessThan:
+		return AlwaysFalse, nil
+	default:
+		return NoNullTest, nil
+	}
+}
+
+// SplitNull removes null from an in list, the list is returned without it along with whether it was there. OData's in
+// is the same as a chain of eq so a null in the list matches a null value, which SQL's IN never does.
+func SplitNull(list []interface{}) ([]interface{}, bool) {
+	hasNull := false
+	ret := make([]interface{}, 0, len(list))
+	for _, value := range list {
+		if value == nil {
+			hasNull = true
+			continue
+		}
+		ret = append(ret, value)
+	}
+	return ret, hasNull
+}
+
+// NullSafeNot reports whether SQL's NOT can be applied to operand as is. OData's comparisons are never null, but SQL's
+// are null when a column they read is and NOT leaves those rows out where OData's not keeps them, so anything else
+// needs its null turned into false first. Null tests, constants and ne, which the SQL backends write null safe, are
+// never null. A bare boolean column is null in OData too.
+func NullSafeNot(operand Operand) bool {
+	op, ok := operand.(*Operation)
+	if !ok {
+		return true
+	}
+	//nolint:exhaustive // Only the operators that are never null
+	switch op.Operator {
+	case lexer.TokenTrue, lexer.TokenFalse, lexer.NotEquals:
+		return true
+	}
+	operands := make([]interface{}, len(op.Operands))
+	for i, operand := range op.Operands {
+		data, err := operand.GetData()
+		if err != nil {
+			return false
+		}
+		operands[i] = data
+	}
+	test, _ := CompareNull(op.Operator, operands)
+	return test != NoNullTest
+}
@@ -0,0 +1,277 @@
+package parser_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pboyd04/godata/filter/parser"
+	"github.com/pboyd04/godata/filter/parser/golang"
+	_ "github.com/pboyd04/godata/filter/parser/gorm"
+	_ "github.com/pboyd04/godata/filter/parser/mongodb"
+	_ "github.com/pboyd04/godata/filter/parser/mysql"
+	"go.mongodb.org/mongo-driver/bson"
+	gormio "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/utils/tests"
+)
+
+type nullTestData struct {
+	input string
+	// matches are the IDs of nullRows the golang evaluator keeps, the other backends should select the same rows
+	matches  []int
+	mysql    string
+	gorm     string
+	gormVars []interface{}
+	mongo    string
+}
+
+//nolint:gochecknoglobals // Just test data
+var nullRows = []map[string]interface{}{
+	{"ID": 1, "Name": "Milk", "Price": 2.5},
+	{"ID": 2, "Name": nil, "Price": nil},
+}
+
+//nolint:gochecknoglobals // Just test data
+var nullTestCases = []nullTestData{
+	{
+		input:   "Name eq null",
+		matches: []int{2},
+		mysql:   "`Name` IS NULL",
+		gorm:    "`Name` IS NULL",
+		mongo:   `{"Name":{"$eq":null}}`,
+	},
+	{
+		input:   "Name ne null",
+		matches: []int{1},
+		mysql:   "`Name` IS NOT NULL",
+		gorm:    "`Name` IS NOT NULL",
+		mongo:   `{"Name":{"$exists":true,"$ne":null}}`,
+	},
+	{
+		input:   "null eq Name",
+		matches: []int{2},
+		mysql:   "`Name` IS NULL",
+		gorm:    "`Name` IS NULL",
+		mongo:   `{"Name":{"$eq":null}}`,
+	},
+	{
+		input:   "null ne Name",
+		matches: []int{1},
+		mysql:   "`Name` IS NOT NULL",
+		gorm:    "`Name` IS NOT NULL",
+		mongo:   `{"Name":{"$exists":true,"$ne":null}}`,
+	},
+	{
+		input:   "null eq null",
+		matches: []int{1, 2},
+		mysql:   "1=1",
+		gorm:    "1 = 1",
+		mongo:   `{}`,
+	},
+	{
+		input:   "Price gt null",
+		matches: []int{},
+		mysql:   "1=0",
+		gorm:    "1 = 0",
+		mongo:   `{"$expr":false}`,
+	},
+	{
+		input:   "Price lt null",
+		matches: []int{},
+		mysql:   "1=0",
+		gorm:    "1 = 0",
+		mongo:   `{"$expr":false}`,
+	},
+	{
+		input:   "Price ge null",
+		matches: []int{2},
+		mysql:   "`Price` IS NULL",
+		gorm:    "`Price` IS NULL",
+		mongo:   `{"Price":{"$eq":null}}`,
+	},
+	{
+		input:   "Price le null",
+		matches: []int{2},
+		mysql:   "`Price` IS NULL",
+		gorm:    "`Price` IS NULL",
+		mongo:   `{"Price":{"$eq":null}}`,
+	},
+	{
+		input:    "Price gt 2",
+		matches:  []int{1},
+		mysql:    "`Price`>2",
+		gorm:     "`Price` > ?",
+		gormVars: []interface{}{2},
+		mongo:    `{"Price":{"$gt":{"$numberInt":"2"}}}`,
+	},
+	{
+		input:   "not (Name eq null)",
+		matches: []int{1},
+		mysql:   "NOT (`Name` IS NULL)",
+		gorm:    "`Name` IS NOT NULL",
+		mongo:   `{"Name":{"$not":{"$eq":null}}}`,
+	},
+	{
+		input:    "Name ne 'Milk'",
+		matches:  []int{2},
+		mysql:    "(`Name`!='Milk' OR `Name` IS NULL)",
+		gorm:     "(`Name` <> ? OR `Name` IS NULL)",
+		gormVars: []interface{}{"Milk"},
+		mongo:    `{"Name":{"$ne":"Milk"}}`,
+	},
+	{
+		input:    "not (Name eq 'Milk')",
+		matches:  []int{2},
+		mysql:    "NOT COALESCE(`Name`='Milk',FALSE)",
+		gorm:     "NOT COALESCE(`Name` = ?, FALSE)",
+		gormVars: []interface{}{"Milk"},
+		mongo:    `{"Name":{"$not":{"$eq":"Milk"}}}`,
+	},
+	{
+		input:    "not (Price gt 2)",
+		matches:  []int{2},
+		mysql:    "NOT COALESCE(`Price`>2,FALSE)",
+		gorm:     "NOT COALESCE(`Price` > ?, FALSE)",
+		gormVars: []interface{}{2},
+		mongo:    `{"Price":{"$not":{"$gt":{"$numberInt":"2"}}}}`,
+	},
+	{
+		input:    "not (Name ne 'Milk')",
+		matches:  []int{1},
+		mysql:    "NOT ((`Name`!='Milk' OR `Name` IS NULL))",
+		gorm:     "NOT (`Name` <> ? OR `Name` IS NULL)",
+		gormVars: []interface{}{"Milk"},
+		mongo:    `{"Name":{"$not":{"$ne":"Milk"}}}`,
+	},
+	{
+		input:    "not contains(Name, 'il')",
+		matches:  []int{2},
+		mysql:    "NOT COALESCE(`Name` LIKE '%il%' ESCAPE '!',FALSE)",
+		gorm:     "NOT COALESCE(`Name` LIKE ? ESCAPE '!', FALSE)",
+		gormVars: []interface{}{"%il%"},
+		mongo:    `{"Name":{"$not":{"$regex":"il"}}}`,
+	},
+	{
+		input:    "Name in ('Milk', null)",
+		matches:  []int{1, 2},
+		mysql:    "(`Name` IN ('Milk') OR `Name` IS NULL)",
+		gorm:     "(`Name` = ? OR `Name` IS NULL)",
+		gormVars: []interface{}{"Milk"},
+		mongo:    `{"Name":{"$in":["Milk",null]}}`,
+	},
+	{
+		input:    "not (Name in ('Milk', null))",
+		matches:  []int{},
+		mysql:    "NOT COALESCE((`Name` IN ('Milk') OR `Name` IS NULL),FALSE)",
+		gorm:     "NOT COALESCE((`Name` = ? OR `Name` IS NULL), FALSE)",
+		gormVars: []interface{}{"Milk"},
+		mongo:    `{"Name":{"$not":{"$in":["Milk",null]}}}`,
+	},
+	{
+		input:    "Name eq null or Price gt 2",
+		matches:  []int{1, 2},
+		mysql:    "`Name` IS NULL OR `Price`>2",
+		gorm:     "(`Name` IS NULL OR `Price` > ?)",
+		gormVars: []interface{}{2},
+		mongo:    `{"$or":[{"Name":{"$eq":null}},{"Price":{"$gt":{"$numberInt":"2"}}}]}`,
+	},
+	{
+		input:    "Price add 1 lt 5",
+		matches:  []int{1},
+		mysql:    "`Price`+1<5",
+		gorm:     "(`Price` + ?) < ?",
+		gormVars: []interface{}{1, 5},
+		mongo: `{"$expr":{"$and":[{"$ne":[{"$ifNull":[{"$add":["$Price",{"$numberInt":"1"}]},null]},null]},` +
+			`{"$lt":[{"$add":["$Price",{"$numberInt":"1"}]},{"$numberInt":"5"}]}]}}`,
+	},
+	{
+		input:    "Price add 1 eq null",
+		matches:  []int{2},
+		mysql:    "`Price`+1 IS NULL",
+		gorm:     "(`Price` + ?) IS NULL",
+		gormVars: []interface{}{1},
+		mongo:    `{"$expr":{"$eq":[{"$ifNull":[{"$add":["$Price",{"$numberInt":"1"}]},null]},null]}}`,
+	},
+}
+
+// TestNullSemantics checks that every backend treats null the same way.
+func TestNullSemantics(t *testing.T) {
+	t.Parallel()
+	db, err := gormio.Open(tests.DummyDialector{}, &gormio.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range nullTestCases {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			common, err := parser.NewParser(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Run("golang", func(t *testing.T) {
+				query, err := common.GetDBQuery("golang")
+				if err != nil {
+					t.Fatal(err)
+				}
+				evaluator, ok := query.(*golang.Evaluator)
+				if !ok {
+					t.Fatalf("expected *golang.Evaluator, got %T", query)
+				}
+				matches := []int{}
+				for _, row := range nullRows {
+					ok, err := evaluator.Matches(row)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if ok {
+						matches = append(matches, row["ID"].(int))
+					}
+				}
+				if !reflect.DeepEqual(matches, tc.matches) {
+					t.Errorf("expected %v, got %v", tc.matches, matches)
+				}
+			})
+			t.Run("mysql", func(t *testing.T) {
+				query, err := common.GetDBQuery("mysql")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if query != tc.mysql {
+					t.Errorf("expected %q, got %q", tc.mysql, query)
+				}
+			})
+			t.Run("gorm", func(t *testing.T) {
+				query, err := common.GetDBQuery("gorm")
+				if err != nil {
+					t.Fatal(err)
+				}
+				expr, ok := query.(clause.Expression)
+				if !ok {
+					t.Fatalf("expected clause.Expression, got %T", query)
+				}
+				stmt := &gormio.Statement{DB: db}
+				clause.Where{Exprs: []clause.Expression{expr}}.Build(stmt)
+				if stmt.SQL.String() != tc.gorm {
+					t.Errorf("expected %q, got %q", tc.gorm, stmt.SQL.String())
+				}
+				if len(stmt.Vars) != len(tc.gormVars) || len(tc.gormVars) > 0 && !reflect.DeepEqual(stmt.Vars, tc.gormVars) {
+					t.Errorf("expected vars %v, got %v", tc.gormVars, stmt.Vars)
+				}
+			})
+			t.Run("mongodb", func(t *testing.T) {
+				query, err := common.GetDBQuery("mongodb")
+				if err != nil {
+					t.Fatal(err)
+				}
+				bytes, err := bson.MarshalExtJSON(query, true, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(bytes) != tc.mongo {
+					t.Errorf("expected %s, got %s", tc.mongo, bytes)
+				}
+			})
+		})
+	}
+}
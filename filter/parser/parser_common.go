@@ -2,12 +2,16 @@ package parser
 
 import (
 	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/pboyd04/godata/filter/lexer"
 )
 
 type Parser struct {
 	lexer  *lexer.Lexer
+	input  string
 	tokens []*lexer.Token
 	op     *Operation
 }
@@ -22,7 +26,7 @@ var parsers = map[string]IParser{}
 
 func NewParser(input string) (*Parser, error) {
 	myLexer := lexer.NewLexer(input)
-	ret := &Parser{lexer: myLexer, tokens: []*lexer.Token{}}
+	ret := &Parser{lexer: myLexer, input: input, tokens: []*lexer.Token{}}
 	token, err := myLexer.NextToken()
 	if err != nil {
 		return nil, err
@@ -34,6 +38,10 @@ func NewParser(input string) (*Parser, error) {
 			return nil, err
 		}
 	}
+	err = validate(ret.tokens, len(input))
+	if err != nil {
+		return nil, err
+	}
 	return ret, nil
 }
 
@@ -55,6 +63,11 @@ type ObjectOperand struct {
 	Properties string
 }
 
+// PropertyPath is a property of a complex type or navigation property, i.e. Address/City.
+type PropertyPath struct {
+	Segments []string
+}
+
 type Operation struct {
 	Operator Operator  `json:"operator"`
 	Operands []Operand `json:"operands,omitempty"`
@@ -62,10 +75,20 @@ type Operation struct {
 
 type tokenComparer func(*lexer.Token) bool
 
+// GetOperation turns the tokens into an expression tree. Errors that can't be pinned on a token cover the whole
+// filter.
 func (p *Parser) GetOperation() (*Operation, error) {
 	if p.op != nil {
 		return p.op, nil
 	}
+	op, err := p.buildOperation()
+	if err != nil {
+		return nil, located(err, p.input)
+	}
+	return op, nil
+}
+
+func (p *Parser) buildOperation() (*Operation, error) {
 	// Make all the tokens one big group...
 	tokenGroup := newTokenGroup(p.tokens)
 	// Handle parentheses
@@ -75,6 +98,11 @@ func (p *Parser) GetOperation() (*Operation, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Split Address/City style paths into their segments
+	err = tokenGroup.propertyPaths()
+	if err != nil {
+		return nil, err
+	}
 	// Start figuring out ops...
 	err = tokenGroup.processTokenGroupOps()
 	if err != nil {
@@ -111,6 +139,72 @@ func (o *Operation) flatten() error {
 			}
 		}
 	}
+	if o.Operator == lexer.Case {
+		return o.caseBranches()
+	}
+	return nil
+}
+
+// caseBranches turns the cond:value pairs of a case() call into alternating condition and value operands.
+func (o *Operation) caseBranches() error {
+	if len(o.Operands) == 0 {
+		return newParserError("case requires one or more condition:value pairs")
+	}
+	branches := make([]Operand, 0, len(o.Operands)/3*2)
+	for i := 0; i < len(o.Operands); i += 3 {
+		if isColon(o.Operands[i]) {
+			return operandError(o.Operands[i], "a case condition")
+		}
+		if i+1 >= len(o.Operands) {
+			if token := firstToken(o.Operands[i]); token != nil {
+				return newParserErrorAt(token, "case condition starting with %s at %d has no value", quoted(token), token.Start)
+			}
+			return newParserError("case requires one or more condition:value pairs")
+		}
+		colon, ok := o.Operands[i+1].(*lexer.Token)
+		if !ok || colon.Type != lexer.Colon {
+			return operandError(o.Operands[i+1], "':' between case condition and value")
+		}
+		if i+2 >= len(o.Operands) || isColon(o.Operands[i+2]) {
+			return newParserErrorAt(colon, "expected a case value after ':' at %d", colon.Start)
+		}
+		branches = append(branches, o.Operands[i], o.Operands[i+2])
+	}
+	o.Operands = branches
+	return nil
+}
+
+func isColon(operand Operand) bool {
+	token, ok := operand.(*lexer.Token)
+	return ok && token.Type == lexer.Colon
+}
+
+// operandError is newTokenError at the first token of an operand, an operand without tokens gives an error that covers
+// the whole filter.
+func operandError(operand Operand, expected string) error {
+	token := firstToken(operand)
+	if token == nil {
+		return newParserError("expected %s", expected)
+	}
+	return newTokenError(token, expected)
+}
+
+// firstToken returns the leftmost token of an operand, nil if it has none.
+func firstToken(operand Operand) *lexer.Token {
+	var children []Operand
+	switch data := operand.(type) {
+	case *lexer.Token:
+		return data
+	case *Operation:
+		children = data.Operands
+	case *tokenGroup:
+		children = data.children
+	}
+	for _, child := range children {
+		if token := firstToken(child); token != nil {
+			return token
+		}
+	}
 	return nil
 }
 
@@ -196,6 +290,24 @@ func (s *SliceOperand) GetData() (interface{}, error) {
 	return s.Slice, nil
 }
 
+func (p *PropertyPath) GetData() (interface{}, error) {
+	return p, nil
+}
+
+func (p *PropertyPath) String() string {
+	return strings.Join(p.Segments, "/")
+}
+
+func newPropertyPath(token *lexer.Token) (*PropertyPath, error) {
+	segments := strings.Split(token.Text, "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, newParserErrorAt(token, "empty segment in property path %s", token.Text)
+		}
+	}
+	return &PropertyPath{Segments: segments}, nil
+}
+
 func (o *ObjectOperand) GetData() (interface{}, error) {
 	data := make(map[string]interface{})
 	err := json.Unmarshal([]byte(o.Properties), &data)
@@ -210,15 +322,27 @@ func (o *Operator) hasParameters() bool {
 }
 
 func (o *Operation) ReplaceOperands(a ...interface{}) (*Operation, error) {
+	values := make(map[string]interface{}, len(a))
+	for i, operand := range a {
+		values[strconv.Itoa(i)] = operand
+	}
+	return o.bind(values, false)
+}
+
+// Bind returns a copy of the operation with the named placeholders (':name') replaced by the values. Unlike
+// ReplaceOperands every placeholder must have a value.
+func (o *Operation) Bind(values map[string]interface{}) (*Operation, error) {
+	return o.bind(values, true)
+}
+
+func (o *Operation) bind(values map[string]interface{}, requireAll bool) (*Operation, error) {
 	newOp, err := o.deepClone()
 	if err != nil {
 		return nil, err
 	}
-	for i, operand := range a {
-		err := newOp.replaceOperand(i, operand)
-		if err != nil {
-			return nil, err
-		}
+	err = replacePlaceholders(newOp.Operands, values, requireAll)
+	if err != nil {
+		return nil, err
 	}
 	return newOp, nil
 }
@@ -228,13 +352,22 @@ func (o *Operation) deepClone() (*Operation, error) {
 	for i, operand := range o.Operands {
 		switch op := operand.(type) {
 		case *lexer.Token:
-			newOp.Operands[i] = &lexer.Token{Type: op.Type, Text: op.Text}
+			newOp.Operands[i] = &lexer.Token{Type: op.Type, Start: op.Start, End: op.End, Text: op.Text}
 		case *Operation:
 			newChild, err := op.deepClone()
 			if err != nil {
 				return nil, err
 			}
 			newOp.Operands[i] = newChild
+		case *PropertyPath, *ObjectOperand:
+			// Paths and objects are never replaced so they can be shared
+			newOp.Operands[i] = op
+		case *SliceOperand:
+			inner, err := (&Operation{Operands: op.Slice}).deepClone()
+			if err != nil {
+				return nil, err
+			}
+			newOp.Operands[i] = &SliceOperand{Slice: inner.Operands}
 		default:
 			return nil, newParserError("unknown type: %T", operand)
 		}
@@ -242,21 +375,40 @@ func (o *Operation) deepClone() (*Operation, error) {
 	return newOp, nil
 }
 
-func (o *Operation) replaceOperand(i int, operand interface{}) error {
-	for _, inOperand := range o.Operands {
+func replacePlaceholders(operands []Operand, values map[string]interface{}, requireAll bool) error {
+	for _, inOperand := range operands {
 		switch op := inOperand.(type) {
 		case *lexer.Token:
-			if op.IsCorrectReplacement(i) {
-				err := op.Replace(operand)
-				if err != nil {
-					return err
+			name, ok := op.PlaceholderName()
+			if !ok {
+				continue
+			}
+			value, ok := values[name]
+			if !ok {
+				if requireAll {
+					return &UnboundPlaceholderError{Name: name, Start: op.Start, End: op.End}
 				}
+				continue
+			}
+			if isSlice(value) {
+				return &InvalidPlaceholderValueError{Name: name, Value: value, Err: ErrSliceOutsideIn, Start: op.Start, End: op.End}
+			}
+			err := op.Replace(value)
+			if err != nil {
+				return &InvalidPlaceholderValueError{Name: name, Value: value, Err: err, Start: op.Start, End: op.End}
+			}
+		case *SliceOperand:
+			err := op.replacePlaceholders(values, requireAll)
+			if err != nil {
+				return err
 			}
 		case *Operation:
-			err := op.replaceOperand(i, operand)
+			err := replacePlaceholders(op.Operands, values, requireAll)
 			if err != nil {
 				return err
 			}
+		case *PropertyPath, *ObjectOperand:
+			continue
 		default:
 			return newParserError("unknown type: %T", inOperand)
 		}
@@ -264,6 +416,46 @@ func (o *Operation) replaceOperand(i int, operand interface{}) error {
 	return nil
 }
 
+// replacePlaceholders replaces the placeholders in an in list, a slice value is expanded into the list.
+func (s *SliceOperand) replacePlaceholders(values map[string]interface{}, requireAll bool) error {
+	expanded := make([]Operand, 0, len(s.Slice))
+	for _, operand := range s.Slice {
+		var name string
+		token, ok := operand.(*lexer.Token)
+		if ok {
+			name, ok = token.PlaceholderName()
+		}
+		value, found := values[name]
+		if !ok || !found || !isSlice(value) {
+			err := replacePlaceholders([]Operand{operand}, values, requireAll)
+			if err != nil {
+				return err
+			}
+			expanded = append(expanded, operand)
+			continue
+		}
+		slice := reflect.ValueOf(value)
+		for i := 0; i < slice.Len(); i++ {
+			elem := &lexer.Token{}
+			err := elem.Replace(slice.Index(i).Interface())
+			if err != nil {
+				return &InvalidPlaceholderValueError{Name: name, Value: value, Err: err, Start: token.Start, End: token.End}
+			}
+			expanded = append(expanded, elem)
+		}
+	}
+	s.Slice = expanded
+	return nil
+}
+
+func isSlice(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	kind := reflect.TypeOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
 func newSliceOperand(tokens []Operand) *SliceOperand {
 	ret := &SliceOperand{Slice: make([]Operand, 0)}
 	for _, token := range tokens {
@@ -293,6 +485,19 @@ func (p *Parser) GetDBQueryWithReplacement(language string, a ...interface{}) (i
 	return parser.GetDBQueryWithReplacement(p, a...)
 }
 
+// Bind returns a copy of the parser with the named placeholders replaced by the values.
+func (p *Parser) Bind(values map[string]interface{}) (*Parser, error) {
+	op, err := p.GetOperation()
+	if err != nil {
+		return nil, err
+	}
+	op, err = op.Bind(values)
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{lexer: p.lexer, input: p.input, tokens: nil, op: op}, nil
+}
+
 func (p *Parser) ReplaceOperands(a ...interface{}) (*Parser, error) {
 	op, err := p.GetOperation()
 	if err != nil {
@@ -302,7 +507,7 @@ func (p *Parser) ReplaceOperands(a ...interface{}) (*Parser, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Parser{lexer: p.lexer, tokens: nil, op: op}, nil
+	return &Parser{lexer: p.lexer, input: p.input, tokens: nil, op: op}, nil
 }
 
 func RegisterParser(name string, parser IParser) {
@@ -2,10 +2,12 @@ package parser_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
 
+	"github.com/pboyd04/godata/errcode"
 	"github.com/pboyd04/godata/filter/lexer"
 	"github.com/pboyd04/godata/filter/parser"
 )
@@ -850,6 +852,58 @@ var testCases = []testData{
 			},
 		},
 	},
+	{
+		input: `case(Status eq 'A':1, Status eq 'B':2, true:0) gt 0`,
+		expectedOperation: parser.Operation{
+			Operator: parser.Operator(lexer.GreaterThan),
+			Operands: []parser.Operand{
+				&parser.Operation{
+					Operator: parser.Operator(lexer.Case),
+					Operands: []parser.Operand{
+						&parser.Operation{
+							Operator: parser.Operator(lexer.Equals),
+							Operands: []parser.Operand{
+								lexer.Token{Text: "Status", Type: lexer.UnquotedString},
+								lexer.Token{Text: "'A'", Type: lexer.SingleQuotedString},
+							},
+						},
+						lexer.Token{Text: "1", Type: lexer.IntegerLiteral},
+						&parser.Operation{
+							Operator: parser.Operator(lexer.Equals),
+							Operands: []parser.Operand{
+								lexer.Token{Text: "Status", Type: lexer.UnquotedString},
+								lexer.Token{Text: "'B'", Type: lexer.SingleQuotedString},
+							},
+						},
+						lexer.Token{Text: "2", Type: lexer.IntegerLiteral},
+						lexer.Token{Text: "true", Type: lexer.TokenTrue},
+						lexer.Token{Text: "0", Type: lexer.IntegerLiteral},
+					},
+				},
+				lexer.Token{Text: "0", Type: lexer.IntegerLiteral},
+			},
+		},
+	},
+	{
+		input: `Address/City eq 'Redmond'`,
+		expectedOperation: parser.Operation{
+			Operator: parser.Operator(lexer.Equals),
+			Operands: []parser.Operand{
+				&parser.PropertyPath{Segments: []string{"Address", "City"}},
+				lexer.Token{Text: "'Redmond'", Type: lexer.SingleQuotedString},
+			},
+		},
+	},
+	{
+		input: `contains(Customer/Address/Street,'NE')`,
+		expectedOperation: parser.Operation{
+			Operator: parser.Operator(lexer.Contains),
+			Operands: []parser.Operand{
+				&parser.PropertyPath{Segments: []string{"Customer", "Address", "Street"}},
+				lexer.Token{Text: "'NE'", Type: lexer.SingleQuotedString},
+			},
+		},
+	},
 	{
 		input: `year eq ':0'`,
 		expectedOperation: parser.Operation{
@@ -862,6 +916,69 @@ var testCases = []testData{
 	},
 }
 
+func TestGetExpressionErrors(t *testing.T) {
+	t.Parallel()
+	for _, input := range []string{"Address//City eq 'Redmond'", "Address/ eq 'Redmond'", "case(Status eq 'A' 1) gt 0"} {
+		myParser, err := parser.NewParser(input)
+		if err == nil {
+			_, err = myParser.GetOperation()
+		}
+		if err == nil {
+			t.Errorf("expected an error for %s", input)
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input    string
+		message  string
+		start    int
+		end      int
+		text     string
+		expected string
+	}{
+		{"(Name eq 'Milk'", "expected ')' to close '(' opened at 0, found end of filter at 15", 15, 15, "", "')' to close '(' opened at 0"},
+		{"Name eq 'Milk')", "unexpected ')' at 14, there's nothing to close", 14, 15, ")", "an operand or operator"},
+		{"contains(Name,'a']", "expected ')' to close '(' opened at 8, found ']' at 17", 17, 18, "]", "')' to close '(' opened at 8"},
+		{"eq 'Milk'", "expected an operand before 'eq' at 0", 0, 2, "eq", "an operand"},
+		{"Name eq", "expected an operand after 'eq' at 5, found end of filter at 7", 7, 7, "", "an operand after 'eq' at 5"},
+		{"Name eq and Price gt 1", "expected an operand after 'eq' at 5, found 'and' at 8", 8, 11, "and", "an operand after 'eq' at 5"},
+		{"not", "expected an operand after 'not' at 0, found end of filter at 3", 3, 3, "", "an operand after 'not' at 0"},
+		{"Name in 'a'", "expected '(', '[' or a parameter alias after 'in' at 5, found 'a' at 8", 8, 11, "'a'", "'(', '[' or a parameter alias after 'in' at 5"},
+		{"Name eq ()", "expected an expression inside '()', found ')' at 9", 9, 10, ")", "an expression inside '()'"},
+		{"Name 'Milk'", "expected an operator after 'Name' at 0, found 'Milk' at 5", 5, 11, "'Milk'", "an operator after 'Name' at 0"},
+		{"Şehir eq 'İzmir' or", "expected an operand after 'or' at 19, found end of filter at 21", 21, 21, "", "an operand after 'or' at 19"},
+		{"Address//City eq 'Redmond'", "empty segment in property path Address//City", 0, 13, "Address//City", ""},
+		{"case(true, 1, 2) gt 0", "expected ':' between case condition and value, found '1' at 11", 11, 12, "1", "':' between case condition and value"},
+		{"case(Status eq 'A':1, true) gt 0", "case condition starting with 'true' at 22 has no value", 22, 26, "true", ""},
+		{"case(true:1:2) gt 0", "expected a case condition, found ':' at 11", 11, 12, ":", "a case condition"},
+		{"case(true:) gt 0", "expected a case value after ':' at 9", 9, 10, ":", ""},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			myParser, err := parser.NewParser(tc.input)
+			if err == nil {
+				_, err = myParser.GetOperation()
+			}
+			var parsingErr *parser.ParsingError
+			if !errors.As(err, &parsingErr) {
+				t.Fatalf("expected a ParsingError, got %v", err)
+			}
+			if err.Error() != tc.message {
+				t.Errorf("expected %q, got %q", tc.message, err.Error())
+			}
+			if parsingErr.Start != tc.start || parsingErr.End != tc.end || parsingErr.Text != tc.text || parsingErr.Expected != tc.expected {
+				t.Errorf("expected %d-%d %q %q, got %d-%d %q %q", tc.start, tc.end, tc.text, tc.expected,
+					parsingErr.Start, parsingErr.End, parsingErr.Text, parsingErr.Expected)
+			}
+		})
+	}
+}
+
 func TestGetExpression(t *testing.T) {
 	t.Parallel()
 	for _, test := range testCases {
@@ -998,6 +1115,14 @@ func compareData(gotData, expectedData interface{}) error {
 				return err
 			}
 		}
+	case *parser.PropertyPath:
+		expectedPath, ok := expectedData.(*parser.PropertyPath)
+		if !ok {
+			return newTestError("expected data is not a property path %T", expectedData)
+		}
+		if gotType.String() != expectedPath.String() {
+			return newTestError("%s != %s", gotType, expectedPath)
+		}
 	case map[string]interface{}:
 		expectedMap, ok := expectedData.(map[string]interface{})
 		if !ok {
@@ -1075,3 +1200,72 @@ func mapCompare(got, expected map[string]interface{}) error {
 	}
 	return nil
 }
+
+func TestComplexity(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input    string
+		expected parser.Complexity
+	}{
+		{"Name eq 'Milk'", parser.Complexity{}},
+		{"((Name eq 'Milk'))", parser.Complexity{Depth: 2}},
+		{"Name in ('Milk', 'Cheese')", parser.Complexity{Depth: 1, LargestInList: 2}},
+		{"Name in ['Milk'] or ID in (1, 2, 3)", parser.Complexity{Depth: 1, LargestInList: 3}},
+		{"Name in ()", parser.Complexity{Depth: 1}},
+		{"contains(tolower(Name),'a,b')", parser.Complexity{Depth: 2, Functions: []parser.Function{
+			{Name: "contains", Span: errcode.Span{Start: 0, End: 8}},
+			{Name: "tolower", Span: errcode.Span{Start: 9, End: 16}},
+		}}},
+		{"hassubset(Tags,['a','b','c','d'])", parser.Complexity{Depth: 2, Functions: []parser.Function{{Name: "hassubset", Span: errcode.Span{Start: 0, End: 9}}}}},
+		{"matchesPattern(Name,'^M')", parser.Complexity{Depth: 1, Functions: []parser.Function{{Name: "matchespattern", Span: errcode.Span{Start: 0, End: 14}}}}},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			p, err := parser.NewParser(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			complexity := p.Complexity()
+			if !reflect.DeepEqual(complexity, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, complexity)
+			}
+		})
+	}
+}
+
+func TestTreeSize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input    string
+		aliases  map[string]string
+		expected parser.TreeSize
+	}{
+		{"Name eq 'Milk'", nil, parser.TreeSize{Nodes: 3}},
+		{"Name in ('a', 'b', 'c') and ID eq 1", nil, parser.TreeSize{Nodes: 9, LargestInList: 3}},
+		{"Name in @names", map[string]string{"@names": `["a","b,c","d","e"]`}, parser.TreeSize{Nodes: 6, LargestInList: 4}},
+		{"hassubset(Tags,['a','b'])", nil, parser.TreeSize{Nodes: 4}},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			p, err := parser.NewParser(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err = p.ResolveAliases(tc.aliases)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size, err := p.TreeSize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, size)
+			}
+		})
+	}
+}
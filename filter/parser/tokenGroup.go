@@ -2,6 +2,7 @@ package parser
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/pboyd04/godata/filter/lexer"
 )
@@ -51,7 +52,7 @@ func (t *tokenGroup) beforeAndAfterOperandProcess(operand Operand, compareFn tok
 		if err != nil {
 			return err
 		}
-	case *ObjectOperand, *SliceOperand:
+	case *ObjectOperand, *SliceOperand, *PropertyPath:
 		// Don't do anything this is already processed
 		break
 	default:
@@ -187,6 +188,14 @@ func (t *tokenGroup) handleInToken(i int) error {
 		t.children = append(t.children[:i-1], op)
 		t.children = append(t.children, tmp...)
 	case *lexer.Token:
+		if next.Type == lexer.ParameterAlias {
+			// The alias will be resolved to an array later
+			op.Operands = []Operand{prev, next}
+			tmp := t.children[i+2:]
+			t.children = append(t.children[:i-1], op)
+			t.children = append(t.children, tmp...)
+			break
+		}
 		if next.Type != lexer.OpenSquareBracket {
 			// Can't find arguments for in
 			return newParserError("expected open square bracket after in, found %s", next.Text)
@@ -409,6 +418,29 @@ func (t *tokenGroup) processTokenGroupOps() error {
 	return nil
 }
 
+func (t *tokenGroup) propertyPaths() error {
+	for i := 0; i < len(t.children); i++ {
+		switch token := t.children[i].(type) {
+		case *lexer.Token:
+			if token.Type == lexer.UnquotedString && strings.Contains(token.Text, "/") {
+				path, err := newPropertyPath(token)
+				if err != nil {
+					return err
+				}
+				t.children[i] = path
+			}
+		case *tokenGroup:
+			err := token.propertyPaths()
+			if err != nil {
+				return err
+			}
+		default:
+			// Don't do anything this is already processed
+		}
+	}
+	return nil
+}
+
 func (t *tokenGroup) removeCommas() {
 	// Remove commas from the token group
 	for i := 0; i < len(t.children); i++ {
@@ -0,0 +1,182 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/pboyd04/godata/filter/lexer"
+)
+
+// validate checks the tokens for the mistakes that can be pinned on a token, i.e. an unclosed parenthesis or an
+// operator missing an operand, before they're turned into an expression tree which can only say that something is
+// wrong. Length is the length of the filter, for errors at its end.
+func validate(tokens []*lexer.Token, length int) error {
+	// open holds the unclosed parentheses, brackets and braces
+	open := []*lexer.Token{}
+	for i, token := range tokens {
+		if closer, ok := closers[token.Type]; ok {
+			open = append(open, token)
+			if token.Type == lexer.OpenParens && i+1 < len(tokens) && tokens[i+1].Type == closer && !allowsEmpty(tokens, i) {
+				return newTokenError(tokens[i+1], "an expression inside '()'")
+			}
+			continue
+		}
+		if isCloser(token.Type) {
+			if len(open) == 0 {
+				return &ParsingError{
+					message:  fmt.Sprintf("unexpected '%s' at %d, there's nothing to close", tokenText(token), token.Start),
+					Start:    token.Start,
+					End:      token.End,
+					Text:     tokenText(token),
+					Expected: "an operand or operator",
+				}
+			}
+			opener := open[len(open)-1]
+			if closers[opener.Type] != token.Type {
+				return newTokenError(token, closeExpectation(opener))
+			}
+			open = open[:len(open)-1]
+		}
+		if inObject(open) {
+			// JSON objects are passed through as they are
+			continue
+		}
+		err := validateOperator(tokens, i, length)
+		if err != nil {
+			return err
+		}
+		err = validateNext(tokens, i)
+		if err != nil {
+			return err
+		}
+	}
+	if len(open) > 0 {
+		return newEndError(length, closeExpectation(open[len(open)-1]))
+	}
+	return nil
+}
+
+// validateNext checks that an operand isn't followed by another operand without an operator between them.
+func validateNext(tokens []*lexer.Token, i int) error {
+	token := tokens[i]
+	if i+1 >= len(tokens) || !isValue(token) {
+		return nil
+	}
+	next := tokens[i+1]
+	// A quoted string straight after a name is a typed literal, i.e. Sales.Color'Red'
+	typed := token.Type == lexer.UnquotedString && token.End == next.Start &&
+		(next.Type == lexer.SingleQuotedString || next.Type == lexer.DoubleQuotedString)
+	if !typed && (isValue(next) && !isCloser(next.Type) || next.Type == lexer.Not || next.HasParameters() || next.Type == lexer.OpenParens) {
+		return newTokenError(next, fmt.Sprintf("an operator after %s at %d", quoted(token), token.Start))
+	}
+	return nil
+}
+
+// validateOperator checks the operands of the operator at i, anything else is fine.
+func validateOperator(tokens []*lexer.Token, i, length int) error {
+	token := tokens[i]
+	if !isBinary(token.Type) && token.Type != lexer.Not {
+		return nil
+	}
+	if isBinary(token.Type) && (i == 0 || !endsOperand(tokens[i-1])) {
+		return &ParsingError{
+			message:  fmt.Sprintf("expected an operand before '%s' at %d", tokenText(token), token.Start),
+			Start:    token.Start,
+			End:      token.Start + len(tokenText(token)),
+			Text:     tokenText(token),
+			Expected: "an operand",
+		}
+	}
+	expected := fmt.Sprintf("an operand after '%s' at %d", tokenText(token), token.Start)
+	if token.Type == lexer.In {
+		expected = fmt.Sprintf("'(', '[' or a parameter alias after 'in' at %d", token.Start)
+	}
+	if i == len(tokens)-1 {
+		return newEndError(length, expected)
+	}
+	next := tokens[i+1]
+	if token.Type == lexer.In && next.Type != lexer.OpenParens && next.Type != lexer.OpenSquareBracket && next.Type != lexer.ParameterAlias {
+		return newTokenError(next, expected)
+	}
+	if !startsOperand(next) {
+		return newTokenError(next, expected)
+	}
+	return nil
+}
+
+//nolint:gochecknoglobals // Lookup table
+var closers = map[lexer.TokenKey]lexer.TokenKey{
+	lexer.OpenParens:        lexer.CloseParens,
+	lexer.OpenSquareBracket: lexer.CloseSquareBracket,
+	lexer.OpenCurlyBrace:    lexer.CloseCurlyBrace,
+}
+
+func isCloser(key lexer.TokenKey) bool {
+	return key == lexer.CloseParens || key == lexer.CloseSquareBracket || key == lexer.CloseCurlyBrace
+}
+
+func closeExpectation(opener *lexer.Token) string {
+	closer := map[lexer.TokenKey]string{lexer.OpenParens: ")", lexer.OpenSquareBracket: "]", lexer.OpenCurlyBrace: "}"}
+	return fmt.Sprintf("'%s' to close '%s' opened at %d", closer[opener.Type], tokenText(opener), opener.Start)
+}
+
+func inObject(open []*lexer.Token) bool {
+	for _, token := range open {
+		if token.Type == lexer.OpenCurlyBrace {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsEmpty is true for the parentheses of an in list, which can be empty.
+func allowsEmpty(tokens []*lexer.Token, i int) bool {
+	return i > 0 && tokens[i-1].Type == lexer.In
+}
+
+func isBinary(key lexer.TokenKey) bool {
+	//nolint:exhaustive // Only the binary operators
+	switch key {
+	case lexer.Equals, lexer.NotEquals, lexer.GreaterThan, lexer.GreaterThanOrEqual, lexer.LessThan,
+		lexer.LessThanOrEqual, lexer.Has, lexer.In, lexer.Add, lexer.Subtract, lexer.Multiply, lexer.Divide,
+		lexer.DivideFloat, lexer.Modulo, lexer.And, lexer.Or:
+		return true
+	default:
+		return false
+	}
+}
+
+// endsOperand is true for a token that can be the last token of an operand.
+func endsOperand(token *lexer.Token) bool {
+	_, opens := closers[token.Type]
+	return !isBinary(token.Type) && token.Type != lexer.Not && !isSeparator(token.Type) && !opens && !token.HasParameters()
+}
+
+// startsOperand is true for a token that can be the first token of an operand.
+func startsOperand(token *lexer.Token) bool {
+	return !isBinary(token.Type) && !isSeparator(token.Type) && !isCloser(token.Type)
+}
+
+func isSeparator(key lexer.TokenKey) bool {
+	return key == lexer.Comma || key == lexer.Colon
+}
+
+// isValue is true for the tokens an operand can end with.
+func isValue(token *lexer.Token) bool {
+	//nolint:exhaustive // Only values
+	switch token.Type {
+	case lexer.TokenTrue, lexer.TokenFalse, lexer.UnquotedString, lexer.SingleQuotedString, lexer.DoubleQuotedString,
+		lexer.NullLiteral, lexer.FloatingPointLiteral, lexer.IntegerLiteral, lexer.ParameterAlias,
+		lexer.DateTimeOffsetLiteral, lexer.DateLiteral, lexer.GUIDLiteral, lexer.CloseParens, lexer.CloseSquareBracket, lexer.CloseCurlyBrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// quoted puts a token in quotes for a message, unless it's a string that already has them.
+func quoted(token *lexer.Token) string {
+	if token.Type == lexer.SingleQuotedString || token.Type == lexer.DoubleQuotedString {
+		return tokenText(token)
+	}
+	return "'" + tokenText(token) + "'"
+}
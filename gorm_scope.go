@@ -0,0 +1,113 @@
+package odata
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pboyd04/godata/orderby"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errQueryNotSupported = errors.New("query not incorrect format")
+
+type gormSettings struct {
+	defaultPageSize int
+	maxPageSize     int
+}
+
+// GormOption configures GormScope.
+type GormOption func(*gormSettings)
+
+// WithDefaultPageSize sets the limit used when the request has no $top.
+func WithDefaultPageSize(size int) GormOption {
+	return func(s *gormSettings) {
+		s.defaultPageSize = size
+	}
+}
+
+// WithMaxPageSize caps the limit, a larger $top is reduced to it. Without a default page size it's also used when the
+// request has no $top.
+func WithMaxPageSize(size int) GormOption {
+	return func(s *gormSettings) {
+		s.maxPageSize = size
+	}
+}
+
+// GormScope applies the query options to a gorm query, use it with db.Scopes. The rules are:
+//   - $filter becomes a Where, an error converting it is added to the db with AddError.
+//   - $orderby becomes Order, $select becomes Select unless it contains *.
+//   - A negative $top is not set, NewQueryOptions defaults it to -1. A $top of 0 is a LIMIT 0 and returns no rows.
+//   - The limit is $top, or the default page size if $top isn't set, capped at the max page size. With neither a
+//     $top nor a page size there's no limit.
+//   - A $skip of zero or less skips nothing.
+//
+// $count isn't applied, it needs a separate query without the limit and offset. The gorm filter parser has to be
+// registered by importing github.com/pboyd04/godata/filter/parser/gorm.
+func GormScope(opts *QueryOptions, options ...GormOption) func(*gorm.DB) *gorm.DB {
+	settings := &gormSettings{}
+	for _, option := range options {
+		option(settings)
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		db, err := settings.apply(db, opts)
+		if err != nil {
+			_ = db.AddError(err)
+		}
+		return db
+	}
+}
+
+func (s *gormSettings) apply(db *gorm.DB, opts *QueryOptions) (*gorm.DB, error) {
+	if opts == nil {
+		opts = NewQueryOptions()
+	}
+	if limit := s.limit(opts.Top); limit >= 0 {
+		db = db.Limit(limit)
+	}
+	if opts.Skip > 0 {
+		db = db.Offset(int(opts.Skip))
+	}
+	if opts.OrderBy != nil {
+		for _, order := range opts.OrderBy.OrderItem {
+			column := clause.Column{Name: strings.ReplaceAll(strings.TrimSpace(order.Property), "/", ".")}
+			db = db.Order(clause.OrderByColumn{Column: column, Desc: order.Direction == orderby.DESC})
+		}
+	}
+	if opts.Filter != nil {
+		query, err := opts.Filter.GetDBQuery("gorm")
+		if err != nil {
+			return db, err
+		}
+		expr, ok := query.(clause.Expression)
+		if !ok {
+			return db, errQueryNotSupported
+		}
+		db = db.Where(expr)
+	}
+	if opts.Select != nil && !selectsAll(*opts.Select) {
+		db = db.Select(*opts.Select)
+	}
+	return db, nil
+}
+
+// limit returns the LIMIT to use, a negative one means there isn't any.
+func (s *gormSettings) limit(top int64) int {
+	limit := int(top)
+	if limit < 0 && s.defaultPageSize > 0 {
+		limit = s.defaultPageSize
+	}
+	if s.maxPageSize > 0 && (limit < 0 || limit > s.maxPageSize) {
+		limit = s.maxPageSize
+	}
+	return limit
+}
+
+func selectsAll(selects []string) bool {
+	for _, s := range selects {
+		if strings.TrimSpace(s) == "*" {
+			return true
+		}
+	}
+	return false
+}
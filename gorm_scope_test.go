@@ -0,0 +1,101 @@
+package odata_test
+
+import (
+	"testing"
+
+	odata "github.com/pboyd04/godata"
+	_ "github.com/pboyd04/godata/filter/parser/gorm"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+type product struct {
+	Name  string
+	Price float64
+}
+
+func TestGormScope(t *testing.T) {
+	t.Parallel()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := odata.NewQueryOptions()
+	assert.NoError(t, full.AddFilter("Price lt 10"))
+	assert.NoError(t, full.AddOrderBy("Name desc"))
+	full.AddSelect([]string{"Name"})
+	full.AddTop(20)
+	full.AddSkip(40)
+	tests := []struct {
+		name     string
+		opts     *odata.QueryOptions
+		options  []odata.GormOption
+		expected string
+		vars     []interface{}
+	}{
+		{
+			name:     "unset",
+			opts:     odata.NewQueryOptions(),
+			expected: "SELECT * FROM `products`",
+		},
+		{
+			name:     "top zero",
+			opts:     &odata.QueryOptions{},
+			options:  []odata.GormOption{odata.WithDefaultPageSize(25)},
+			expected: "SELECT * FROM `products` LIMIT ?",
+			vars:     []interface{}{0},
+		},
+		{
+			name:     "all",
+			opts:     full,
+			expected: "SELECT `name` FROM `products` WHERE `Price` < ? ORDER BY `Name` DESC LIMIT ? OFFSET ?",
+			vars:     []interface{}{10, 20, 40},
+		},
+		{
+			name:     "capped",
+			opts:     full,
+			options:  []odata.GormOption{odata.WithMaxPageSize(10)},
+			expected: "SELECT `name` FROM `products` WHERE `Price` < ? ORDER BY `Name` DESC LIMIT ? OFFSET ?",
+			vars:     []interface{}{10, 10, 40},
+		},
+		{
+			name:     "default page size",
+			opts:     odata.NewQueryOptions(),
+			options:  []odata.GormOption{odata.WithDefaultPageSize(25), odata.WithMaxPageSize(100)},
+			expected: "SELECT * FROM `products` LIMIT ?",
+			vars:     []interface{}{25},
+		},
+		{
+			name:     "max page size only",
+			opts:     nil,
+			options:  []odata.GormOption{odata.WithMaxPageSize(100)},
+			expected: "SELECT * FROM `products` LIMIT ?",
+			vars:     []interface{}{100},
+		},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var products []product
+			res := db.Scopes(odata.GormScope(tc.opts, tc.options...)).Find(&products)
+			assert.NoError(t, res.Error)
+			assert.Equal(t, tc.expected, res.Statement.SQL.String())
+			assert.ElementsMatch(t, tc.vars, res.Statement.Vars)
+		})
+	}
+}
+
+func TestGormScopeError(t *testing.T) {
+	t.Parallel()
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := odata.NewQueryOptions()
+	assert.NoError(t, opts.AddFilter("hassubset(Names,'a')"))
+	var products []product
+	res := db.Scopes(odata.GormScope(opts)).Find(&products)
+	assert.Error(t, res.Error)
+}
@@ -0,0 +1,27 @@
+//go:build !no_chi
+// +build !no_chi
+
+package middleware
+
+import (
+	"net/http"
+)
+
+// ChiMiddleware stores the query options on the request context, see GetOdataFromContext. chi middlewares are plain
+// net/http ones so this doesn't need chi itself, use it with r.Use. Mux middlewares run before routing so POST
+// .../$query requests are routed as the GET they're rewritten to, see RewriteQueryRequest.
+func (o *OdataMiddleware) ChiMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, err := o.RewriteQueryRequest(r)
+		if err != nil {
+			o.WriteError(w, err)
+			return
+		}
+		queryOptions, err := o.Parse(r.URL.Query())
+		if err != nil {
+			o.WriteError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), queryOptions)))
+	})
+}
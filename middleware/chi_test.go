@@ -0,0 +1,27 @@
+//go:build !no_chi
+// +build !no_chi
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/middleware"
+)
+
+func TestChiMiddleware(t *testing.T) {
+	t.Parallel()
+	testAdapter(t, func(t *testing.T, rawQuery string) (int, string, *odata.QueryOptions) {
+		t.Helper()
+		var queryOptions *odata.QueryOptions
+		handler := middleware.NewOdataMiddleware(nil).ChiMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			queryOptions = middleware.GetOdataFromContext(r.Context())
+		}))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test?"+rawQuery, nil))
+		return recorder.Code, recorder.Body.String(), queryOptions
+	})
+}
@@ -0,0 +1,33 @@
+//go:build !no_echo
+// +build !no_echo
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EchoMiddleware stores the query options with c.Set and on the request context, see GetOdataFromContext. POST
+// .../$query requests are rewritten to the GET they stand for (see RewriteQueryRequest). Use it with e.Pre for them to
+// be routed as that GET, with e.Use echo has already routed them so the $query routes have to be registered too.
+func (o *OdataMiddleware) EchoMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if IsQueryRequest(c.Request()) {
+			request, err := o.RewriteQueryRequest(c.Request())
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, o.NewErrorResponse(err))
+			}
+			// echo routes e.Pre requests with the request it started with, so that's the one that has to change
+			*c.Request() = *request
+		}
+		queryOptions, err := o.Parse(c.Request().URL.Query())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, o.NewErrorResponse(err))
+		}
+		c.Set(string(ContextKey), queryOptions)
+		c.SetRequest(c.Request().WithContext(NewContext(c.Request().Context(), queryOptions)))
+		return next(c)
+	}
+}
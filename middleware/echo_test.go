@@ -0,0 +1,81 @@
+//go:build !no_echo
+// +build !no_echo
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/middleware"
+)
+
+func TestEchoMiddleware(t *testing.T) {
+	t.Parallel()
+	testAdapter(t, func(t *testing.T, rawQuery string) (int, string, *odata.QueryOptions) {
+		t.Helper()
+		var queryOptions *odata.QueryOptions
+		e := echo.New()
+		e.Use(middleware.NewOdataMiddleware(nil).EchoMiddleware)
+		e.GET("/test", func(c echo.Context) error {
+			queryOptions = middleware.GetOdataFromContext(c.Request().Context())
+			if c.Get(string(middleware.ContextKey)) != queryOptions {
+				t.Error("c.Get and the request context should hold the same query options")
+			}
+			return c.NoContent(http.StatusOK)
+		})
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test?"+rawQuery, nil))
+		return recorder.Code, recorder.Body.String(), queryOptions
+	})
+}
+
+func TestEchoQueryRequest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path         string
+		pre          bool
+		expectedCode int
+	}{
+		{path: "/products/$query", expectedCode: http.StatusOK},
+		{path: "/products/$query", pre: true, expectedCode: http.StatusOK},
+		{path: "/missing/$query", pre: true, expectedCode: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+			var queryOptions *odata.QueryOptions
+			handler := func(c echo.Context) error {
+				queryOptions = middleware.GetOdataFromContext(c.Request().Context())
+				return c.NoContent(http.StatusOK)
+			}
+			m := middleware.NewOdataMiddleware(nil)
+			e := echo.New()
+			if tc.pre {
+				e.Pre(m.EchoMiddleware)
+			} else {
+				e.Use(m.EchoMiddleware)
+				e.POST("/products/$query", handler)
+			}
+			e.GET("/products", handler)
+			request := httptest.NewRequest(http.MethodPost, tc.path+"?$skip=2", strings.NewReader("$top=5"))
+			request.Header.Set("Content-Type", "text/plain")
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("expected %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if queryOptions == nil || queryOptions.Top != 5 || queryOptions.Skip != 2 {
+				t.Errorf("expected $top=5 and $skip=2, got %+v", queryOptions)
+			}
+		})
+	}
+}
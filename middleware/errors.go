@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pboyd04/godata/errcode"
+)
+
+// ErrNegativeValue is returned for a negative $top or $skip.
+var ErrNegativeValue = errors.New("value must not be negative")
+
+// LimitExceededError is returned when a request is outside the middleware's Limits.
+type LimitExceededError struct {
+	Option string
+	Limit  string
+	Max    int
+	Value  int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s %s of %d exceeds the limit of %d", e.Option, e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitExceededError) ErrorCode() errcode.Code {
+	return errcode.LimitExceeded
+}
+
+// FunctionNotAllowedError is returned for a $filter function that isn't in the middleware's allowed list.
+type FunctionNotAllowedError struct {
+	Function string
+	Span     errcode.Span
+}
+
+func (e *FunctionNotAllowedError) Error() string {
+	return fmt.Sprintf("$filter function %s is not allowed", e.Function)
+}
+
+func (e *FunctionNotAllowedError) ErrorCode() errcode.Code {
+	return errcode.UnsupportedFunction
+}
+
+func (e *FunctionNotAllowedError) ErrorSpan() errcode.Span {
+	return e.Span
+}
+
+// DuplicateOptionError is returned when a query option is given more than once, including under different names when
+// lenient option names are enabled (i.e. $filter and filter).
+type DuplicateOptionError struct {
+	Option string
+	Count  int
+}
+
+func (e *DuplicateOptionError) Error() string {
+	return fmt.Sprintf("%s is given %d times", e.Option, e.Count)
+}
+
+func (e *DuplicateOptionError) ErrorCode() errcode.Code {
+	return errcode.DuplicateOption
+}
+
+// UnsupportedContentTypeError is returned for a $query request with a body that isn't text/plain, a form or JSON.
+type UnsupportedContentTypeError struct {
+	ContentType string
+}
+
+func (e *UnsupportedContentTypeError) Error() string {
+	return fmt.Sprintf("unsupported $query content type %q", e.ContentType)
+}
+
+func (e *UnsupportedContentTypeError) ErrorCode() errcode.Code {
+	return errcode.UnsupportedMediaType
+}
+
+// OptionError is an error in one of the query options, Option is its name (i.e. $filter) and Input its value. Wrap
+// errors from translating a filter in one to report them with WriteError.
+type OptionError struct {
+	Option string
+	Input  string
+	Err    error
+}
+
+func (e *OptionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
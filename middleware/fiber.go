@@ -0,0 +1,42 @@
+//go:build !no_fiber
+// +build !no_fiber
+
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FiberMiddleware stores the query options in c.Locals and on the user context, see GetOdataFromContext. Use it with
+// app.Use. POST .../$query requests are rewritten to the GET they stand for (see RewriteQueryRequest), fiber routes
+// the handlers after app.Use ones as that GET.
+func (o *OdataMiddleware) FiberMiddleware(c *fiber.Ctx) error {
+	query := url.Values{}
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		query.Add(string(key), string(value))
+	})
+	if c.Method() == http.MethodPost && strings.HasSuffix(c.Path(), QuerySegment) {
+		var err error
+		query, err = o.queryFromBody(query, c.Get(fiber.HeaderContentType), bytes.NewReader(c.Body()))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(o.NewErrorResponse(err))
+		}
+		c.Method(http.MethodGet)
+		c.Path(strings.TrimSuffix(c.Path(), QuerySegment))
+		c.Request().URI().SetQueryString(query.Encode())
+		c.Request().ResetBody()
+		c.Request().Header.Del(fiber.HeaderContentType)
+	}
+	queryOptions, err := o.Parse(query)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(o.NewErrorResponse(err))
+	}
+	c.Locals(string(ContextKey), queryOptions)
+	c.SetUserContext(NewContext(c.UserContext(), queryOptions))
+	return c.Next()
+}
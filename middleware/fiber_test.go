@@ -0,0 +1,83 @@
+//go:build !no_fiber
+// +build !no_fiber
+
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/middleware"
+)
+
+func TestFiberMiddleware(t *testing.T) {
+	t.Parallel()
+	testAdapter(t, func(t *testing.T, rawQuery string) (int, string, *odata.QueryOptions) {
+		t.Helper()
+		var queryOptions *odata.QueryOptions
+		app := fiber.New()
+		app.Use(middleware.NewOdataMiddleware(nil).FiberMiddleware)
+		app.Get("/test", func(c *fiber.Ctx) error {
+			queryOptions = middleware.GetOdataFromContext(c.UserContext())
+			if c.Locals(string(middleware.ContextKey)) != queryOptions {
+				t.Error("c.Locals and the user context should hold the same query options")
+			}
+			return c.SendStatus(http.StatusOK)
+		})
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/test?"+rawQuery, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body), queryOptions
+	})
+}
+
+func TestFiberQueryRequest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path         string
+		expectedCode int
+	}{
+		{path: "/products/$query", expectedCode: http.StatusOK},
+		{path: "/missing/$query", expectedCode: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+			var queryOptions *odata.QueryOptions
+			app := fiber.New()
+			app.Use(middleware.NewOdataMiddleware(nil).FiberMiddleware)
+			app.Get("/products", func(c *fiber.Ctx) error {
+				queryOptions = middleware.GetOdataFromContext(c.UserContext())
+				return c.SendStatus(http.StatusOK)
+			})
+			request := httptest.NewRequest(http.MethodPost, tc.path+"?$skip=2", strings.NewReader("$top=5"))
+			request.Header.Set("Content-Type", "text/plain")
+			resp, err := app.Test(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.expectedCode {
+				t.Fatalf("expected %d, got %d", tc.expectedCode, resp.StatusCode)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if queryOptions == nil || queryOptions.Top != 5 || queryOptions.Skip != 2 {
+				t.Errorf("expected $top=5 and $skip=2, got %+v", queryOptions)
+			}
+		})
+	}
+}
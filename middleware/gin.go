@@ -4,21 +4,55 @@
 package middleware
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
-	odata "github.com/pboyd04/godata"
 )
 
+// ginRewrittenKey marks a gin context whose POST .../$query request has been rewritten to a GET.
+const ginRewrittenKey = "odata.rewritten"
+
+// GinMiddleware stores the query options with c.Set and on the request context, see GetOdataFromContext. POST
+// .../$query requests are rewritten to the GET they stand for (see RewriteQueryRequest), gin has already routed them
+// by then so either register the $query routes too or add GinQueryHandler as the NoRoute handler.
 func (o *OdataMiddleware) GinMiddleware(c *gin.Context) {
-	queryOptions := odata.QueryOptions{}
-	for k, v := range c.Request.URL.Query() {
-		if fn, ok := o.preProcessingFunctions[k]; ok {
-			if err := fn(v[0], &queryOptions); err != nil {
-				c.JSON(400, gin.H{"error": err.Error()})
+	if IsQueryRequest(c.Request) {
+		request, err := o.RewriteQueryRequest(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, o.NewErrorResponse(err))
+			return
+		}
+		c.Request = request
+		c.Set(ginRewrittenKey, true)
+	}
+	queryOptions, err := o.Parse(c.Request.URL.Query())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, o.NewErrorResponse(err))
+		return
+	}
+	c.Set(string(ContextKey), queryOptions)
+	c.Request = c.Request.WithContext(NewContext(c.Request.Context(), queryOptions))
+	c.Next()
+}
+
+// GinQueryHandler routes POST .../$query requests that don't have a route of their own to the GET route they stand
+// for, use it with engine.NoRoute. Other requests get gin's usual 404.
+func (o *OdataMiddleware) GinQueryHandler(engine *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool(ginRewrittenKey) {
+			if !IsQueryRequest(c.Request) {
 				return
 			}
+			request, err := o.RewriteQueryRequest(c.Request)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, o.NewErrorResponse(err))
+				return
+			}
+			c.Request = request
 		}
+		// gin has already set the status to 404 for NoRoute. HandleContext resets the context, so the rewritten request
+		// isn't marked and can't be routed here again.
+		c.Status(http.StatusOK)
+		engine.HandleContext(c)
 	}
-	c.Set(string(ContextKey), &queryOptions)
-	c.Next()
-	// Post processing
 }
@@ -0,0 +1,81 @@
+//go:build !no_gin
+// +build !no_gin
+
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/middleware"
+)
+
+func TestGinMiddleware(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	testAdapter(t, func(t *testing.T, rawQuery string) (int, string, *odata.QueryOptions) {
+		t.Helper()
+		var queryOptions *odata.QueryOptions
+		router := gin.New()
+		router.Use(middleware.NewOdataMiddleware(nil).GinMiddleware)
+		router.GET("/test", func(c *gin.Context) {
+			queryOptions = middleware.GetOdataFromContext(c.Request.Context())
+			if stored, _ := c.Get(string(middleware.ContextKey)); stored != queryOptions {
+				t.Error("c.Get and the request context should hold the same query options")
+			}
+		})
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test?"+rawQuery, nil))
+		return recorder.Code, recorder.Body.String(), queryOptions
+	})
+}
+
+func TestGinQueryRequest(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		path         string
+		register     bool
+		expectedCode int
+	}{
+		{path: "/products/$query", register: true, expectedCode: http.StatusOK},
+		{path: "/products/$query", expectedCode: http.StatusOK},
+		{path: "/missing/$query", expectedCode: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+			var queryOptions *odata.QueryOptions
+			handler := func(c *gin.Context) {
+				queryOptions = middleware.GetOdataFromContext(c.Request.Context())
+			}
+			m := middleware.NewOdataMiddleware(nil)
+			router := gin.New()
+			router.Use(m.GinMiddleware)
+			router.GET("/products", handler)
+			if tc.register {
+				router.POST("/products/$query", handler)
+			} else {
+				router.NoRoute(m.GinQueryHandler(router))
+			}
+			request := httptest.NewRequest(http.MethodPost, tc.path+"?$skip=2", strings.NewReader("$top=5"))
+			request.Header.Set("Content-Type", "text/plain")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != tc.expectedCode {
+				t.Fatalf("expected %d, got %d", tc.expectedCode, recorder.Code)
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if queryOptions == nil || queryOptions.Top != 5 || queryOptions.Skip != 2 {
+				t.Errorf("expected $top=5 and $skip=2, got %+v", queryOptions)
+			}
+		})
+	}
+}
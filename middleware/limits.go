@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"strings"
+
+	odata "github.com/pboyd04/godata"
+)
+
+// DefaultMaxQueryBodySize is the biggest POST .../$query body allowed when Limits.MaxQueryBodySize is zero.
+const DefaultMaxQueryBodySize = 1 << 20
+
+// Limits bounds what a request can ask for, a zero value means no limit except for MaxQueryBodySize. Requests outside
+// the limits get a 400 before the filter is turned into a query.
+type Limits struct {
+	// MaxTop is the largest $top allowed. It's also the page size for a request without $top when there's no DefaultTop.
+	MaxTop int64
+	// DefaultTop is used when the request has no $top, capped at MaxTop.
+	DefaultTop int64
+	// MaxFilterLength is the longest $filter allowed, in bytes.
+	MaxFilterLength int
+	// MaxExpressionDepth is the deepest nesting of parentheses and brackets allowed in $filter.
+	MaxExpressionDepth int
+	// MaxFilterNodes is the most operators and operands allowed in $filter, counted once parameter aliases are resolved.
+	MaxFilterNodes int
+	// MaxInListSize is the most values allowed in an in list, counted once parameter aliases are resolved.
+	MaxInListSize int
+	// MaxOrderByItems is the most $orderby properties allowed.
+	MaxOrderByItems int
+	// MaxQueryBodySize is the biggest body allowed for a POST .../$query request, in bytes. Unlike the other limits
+	// zero means DefaultMaxQueryBodySize, the body has to be read into memory, use a negative value for no limit.
+	MaxQueryBodySize int
+	// AllowedFunctions are the only functions allowed in $filter, i.e. contains or tolower. Nil allows every function.
+	AllowedFunctions []string
+}
+
+// SetLimits replaces the limits, by default only the $query body size is limited.
+func (o *OdataMiddleware) SetLimits(limits Limits) {
+	o.limits = limits
+}
+
+// queryBodySize is the biggest $query body allowed, 0 for no limit.
+func (l *Limits) queryBodySize() int {
+	switch {
+	case l.MaxQueryBodySize == 0:
+		return DefaultMaxQueryBodySize
+	case l.MaxQueryBodySize < 0:
+		return 0
+	default:
+		return l.MaxQueryBodySize
+	}
+}
+
+// defaultTop is the $top for a request without one, -1 when the page size isn't limited.
+func (l *Limits) defaultTop() int64 {
+	switch {
+	case l.DefaultTop > 0 && (l.MaxTop <= 0 || l.DefaultTop <= l.MaxTop):
+		return l.DefaultTop
+	case l.MaxTop > 0:
+		return l.MaxTop
+	default:
+		return -1
+	}
+}
+
+// checkLength runs before an option is parsed.
+func (l *Limits) checkLength(key, value string) error {
+	if key == "$filter" && l.MaxFilterLength > 0 && len(value) > l.MaxFilterLength {
+		return &LimitExceededError{Option: key, Limit: "length", Max: l.MaxFilterLength, Value: len(value)}
+	}
+	return nil
+}
+
+// check runs once the options are parsed but before parameter aliases are resolved.
+func (l *Limits) check(queryOptions *odata.QueryOptions) error {
+	if queryOptions.Top < 0 {
+		queryOptions.Top = l.defaultTop()
+	}
+	if l.MaxTop > 0 && queryOptions.Top > l.MaxTop {
+		return &LimitExceededError{Option: "$top", Limit: "value", Max: int(l.MaxTop), Value: int(queryOptions.Top)}
+	}
+	if l.MaxOrderByItems > 0 && queryOptions.OrderBy != nil && len(queryOptions.OrderBy.OrderItem) > l.MaxOrderByItems {
+		return &LimitExceededError{Option: "$orderby", Limit: "items", Max: l.MaxOrderByItems, Value: len(queryOptions.OrderBy.OrderItem)}
+	}
+	if queryOptions.Filter == nil {
+		return nil
+	}
+	complexity := queryOptions.Filter.Complexity()
+	if l.MaxExpressionDepth > 0 && complexity.Depth > l.MaxExpressionDepth {
+		return &LimitExceededError{Option: "$filter", Limit: "depth", Max: l.MaxExpressionDepth, Value: complexity.Depth}
+	}
+	if l.AllowedFunctions != nil {
+		for _, function := range complexity.Functions {
+			if !l.allowed(function.Name) {
+				return &FunctionNotAllowedError{Function: function.Name, Span: function.Span}
+			}
+		}
+	}
+	return nil
+}
+
+// checkTree runs once parameter aliases are resolved, so the values they hold count too.
+func (l *Limits) checkTree(queryOptions *odata.QueryOptions) error {
+	if queryOptions.Filter == nil || l.MaxFilterNodes <= 0 && l.MaxInListSize <= 0 {
+		return nil
+	}
+	size, err := queryOptions.Filter.TreeSize()
+	if err != nil {
+		return err
+	}
+	if l.MaxInListSize > 0 && size.LargestInList > l.MaxInListSize {
+		return &LimitExceededError{Option: "$filter", Limit: "in list size", Max: l.MaxInListSize, Value: size.LargestInList}
+	}
+	if l.MaxFilterNodes > 0 && size.Nodes > l.MaxFilterNodes {
+		return &LimitExceededError{Option: "$filter", Limit: "nodes", Max: l.MaxFilterNodes, Value: size.Nodes}
+	}
+	return nil
+}
+
+func (l *Limits) allowed(function string) bool {
+	for _, allowed := range l.AllowedFunctions {
+		if strings.EqualFold(allowed, function) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,11 +3,16 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
 	odata "github.com/pboyd04/godata"
+	"github.com/pboyd04/godata/orderby"
 )
 
 type contextKeyType string
@@ -15,6 +20,9 @@ type processingFn func(string, *odata.QueryOptions) error
 type OdataMiddleware struct {
 	handler                http.Handler
 	preProcessingFunctions map[string]processingFn
+	limits                 Limits
+	errorSnippets          bool
+	lenientOptionNames     bool
 }
 
 const (
@@ -80,24 +88,137 @@ func (o *OdataMiddleware) DisableCountSupport() {
 	delete(o.preProcessingFunctions, "$count")
 }
 
-func (o *OdataMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	odata := odata.NewQueryOptions()
-	for key, fn := range o.preProcessingFunctions {
-		queryContent := r.URL.Query().Get(key)
-		if queryContent != "" {
-			err := fn(queryContent, odata)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
+// EnableLenientOptionNames accepts system query options without the $ prefix and in any case, i.e. filter or $Filter
+// as well as $filter, as OData 4.01 allows.
+func (o *OdataMiddleware) EnableLenientOptionNames() {
+	o.lenientOptionNames = true
+}
+
+func (o *OdataMiddleware) DisableLenientOptionNames() {
+	o.lenientOptionNames = false
+}
+
+// Parse is the framework neutral core of every middleware, it reads the enabled query options from a query string.
+// An option given more than once is an error and empty options are ignored, then the limits are checked and
+// parameter aliases (@name) are resolved last. Errors are OptionErrors saying which option is wrong, when several
+// options are wrong they're all returned joined together.
+func (o *OdataMiddleware) Parse(query url.Values) (*odata.QueryOptions, error) {
+	queryOptions := odata.NewQueryOptions()
+	options, errs := o.options(query)
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	// Always report the errors in the same order
+	sort.Strings(keys)
+	for _, key := range keys {
+		queryContent := options[key]
+		if queryContent == "" {
+			continue
+		}
+		err := o.limits.checkLength(key, queryContent)
+		if err == nil {
+			err = o.preProcessingFunctions[key](queryContent, queryOptions)
+		}
+		if err != nil {
+			errs = append(errs, &OptionError{Option: key, Input: queryContent, Err: err})
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return targetOf(errs[i]) < targetOf(errs[j])
+	})
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	if len(errs) > 1 {
+		return nil, errors.Join(errs...)
+	}
+	// Before the aliases are resolved, that's when the filter is first turned into an expression tree
+	err := o.limits.check(queryOptions)
+	if err != nil {
+		return nil, limitError(err, options)
+	}
+	err = queryOptions.ResolveAliases(getAliases(query))
+	if err != nil {
+		option := "$filter"
+		var aliasErr *orderby.UnresolvedAliasError
+		if errors.As(err, &aliasErr) {
+			option = "$orderby"
+		}
+		return nil, &OptionError{Option: option, Input: options[option], Err: err}
+	}
+	err = o.limits.checkTree(queryOptions)
+	if err != nil {
+		return nil, limitError(err, options)
+	}
+	return queryOptions, nil
+}
+
+// limitError puts a limit error on the option that broke it, anything else is about $filter.
+func limitError(err error, options map[string]string) error {
+	option := "$filter"
+	var limitErr *LimitExceededError
+	if errors.As(err, &limitErr) {
+		option = limitErr.Option
+	}
+	return &OptionError{Option: option, Input: options[option], Err: err}
+}
+
+// options returns the value of each enabled option in the query string by its canonical name, i.e. $filter.
+func (o *OdataMiddleware) options(query url.Values) (map[string]string, []error) {
+	ret := make(map[string]string)
+	counts := make(map[string]int)
+	for key, values := range query {
+		name := key
+		if o.lenientOptionNames {
+			name = strings.ToLower(name)
+			if !strings.HasPrefix(name, "$") {
+				name = "$" + name
 			}
 		}
+		if _, ok := o.preProcessingFunctions[name]; !ok || len(values) == 0 {
+			continue
+		}
+		counts[name] += len(values)
+		ret[name] = values[0]
+	}
+	errs := []error{}
+	for name, count := range counts {
+		if count > 1 {
+			errs = append(errs, &OptionError{Option: name, Err: &DuplicateOptionError{Option: name, Count: count}})
+			delete(ret, name)
+		}
+	}
+	return ret, errs
+}
+
+// Parse reads the query options from a query string with every option enabled.
+func Parse(query url.Values) (*odata.QueryOptions, error) {
+	return NewOdataMiddleware(nil).Parse(query)
+}
+
+// ServeHTTP also handles POST .../$query requests, see RewriteQueryRequest.
+func (o *OdataMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, err := o.RewriteQueryRequest(r)
+	if err != nil {
+		o.WriteError(w, err)
+		return
+	}
+	queryOptions, err := o.Parse(r.URL.Query())
+	if err != nil {
+		o.WriteError(w, err)
+		return
 	}
-	ctx := context.WithValue(r.Context(), ContextKey, odata)
 	if o.handler != nil {
-		o.handler.ServeHTTP(w, r.WithContext(ctx))
+		o.handler.ServeHTTP(w, r.WithContext(NewContext(r.Context(), queryOptions)))
 	}
 }
 
+// NewContext returns a copy of ctx carrying the query options, GetOdataFromContext reads them back.
+func NewContext(ctx context.Context, queryOptions *odata.QueryOptions) context.Context {
+	return context.WithValue(ctx, ContextKey, queryOptions)
+}
+
 func GetOdataFromContext(ctx context.Context) *odata.QueryOptions {
 	ctxKey, ok := ctx.Value(ContextKey).(*odata.QueryOptions)
 	if !ok {
@@ -106,6 +227,17 @@ func GetOdataFromContext(ctx context.Context) *odata.QueryOptions {
 	return ctxKey
 }
 
+// getAliases returns the parameter aliases (@name) from the query string.
+func getAliases(query url.Values) map[string]string {
+	aliases := make(map[string]string)
+	for key, values := range query {
+		if strings.HasPrefix(key, "@") && len(values) > 0 {
+			aliases[key] = values[0]
+		}
+	}
+	return aliases
+}
+
 func (o *OdataMiddleware) addPreProcessingFunction(name string, fn processingFn) {
 	if o.preProcessingFunctions == nil {
 		o.preProcessingFunctions = make(map[string]processingFn)
@@ -131,6 +263,9 @@ func processTop(top string, o *odata.QueryOptions) error {
 	if err != nil {
 		return err
 	}
+	if topInt < 0 {
+		return fmt.Errorf("$top: %w", ErrNegativeValue)
+	}
 	o.AddTop(topInt)
 	return nil
 }
@@ -140,6 +275,9 @@ func processSkip(skip string, o *odata.QueryOptions) error {
 	if err != nil {
 		return err
 	}
+	if skipInt < 0 {
+		return fmt.Errorf("$skip: %w", ErrNegativeValue)
+	}
 	o.AddSkip(skipInt)
 	return nil
 }
@@ -1,10 +1,14 @@
 package middleware_test
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	odata "github.com/pboyd04/godata"
 	_ "github.com/pboyd04/godata/filter/parser/mysql"
 	"github.com/pboyd04/godata/middleware"
 	"github.com/pboyd04/godata/orderby"
@@ -94,6 +98,33 @@ var tests = []testData{
 		expectedSkip:    -1,
 		expectedCount:   false,
 	},
+	{
+		input:           "$filter=Price%20lt%20@max&@max=10",
+		expectedFilter:  "`Price`<10",
+		expectedSelect:  nil,
+		expectedOrderBy: nil,
+		expectedTop:     -1,
+		expectedSkip:    -1,
+		expectedCount:   false,
+	},
+	{
+		input:           "$filter=Name%20in%20@names&@names=[%22Milk%22,%22Cheese%22]",
+		expectedFilter:  "`Name` IN ('Milk','Cheese')",
+		expectedSelect:  nil,
+		expectedOrderBy: nil,
+		expectedTop:     -1,
+		expectedSkip:    -1,
+		expectedCount:   false,
+	},
+	{
+		input:           "$orderby=@sort%20desc&@sort=Name",
+		expectedFilter:  "",
+		expectedSelect:  nil,
+		expectedOrderBy: &orderby.OrderBy{OrderItem: []orderby.OrderItem{{Property: "Name", Direction: orderby.DESC}}},
+		expectedTop:     -1,
+		expectedSkip:    -1,
+		expectedCount:   false,
+	},
 	{
 		input:           "$filter=Name%20eq%20'Bob'&$select=Name,Test&$orderby=Name&$top=10&$skip=100&$count=true",
 		expectedFilter:  "`Name`='Bob'",
@@ -134,53 +165,136 @@ func TestMiddlewareEmpty(t *testing.T) {
 	middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
 }
 
-//nolint:gocognit,cyclop // This is just a test
 func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	testAdapter(t, func(t *testing.T, rawQuery string) (int, string, *odata.QueryOptions) {
+		t.Helper()
+		var queryOptions *odata.QueryOptions
+		middleware := middleware.NewOdataMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			queryOptions = middleware.GetOdataFromContext(r.Context())
+		}))
+		recorder := httptest.NewRecorder()
+		middleware.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test?"+rawQuery, nil))
+		return recorder.Code, recorder.Body.String(), queryOptions
+	})
+}
+
+func TestParse(t *testing.T) {
 	t.Parallel()
 	for _, test := range tests {
 		tc := test
-		t.Run(test.input, func(t *testing.T) {
+		t.Run(tc.input, func(t *testing.T) {
 			t.Parallel()
-			middleware := middleware.NewOdataMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
-				odata := middleware.GetOdataFromContext(r.Context())
-				if odata == nil {
-					t.Fatal("Missing odata filter")
-				}
-				if odata.Filter != nil && tc.expectedFilter == "" {
-					t.Error("Filter should be nil")
-				} else if odata.Filter != nil {
-					data, err := odata.Filter.GetDBQuery("mysql")
-					if err != nil {
-						t.Fatal(err)
-					}
-					//nolint:forcetypeassert // Just test code
-					if data.(string) != tc.expectedFilter {
-						//nolint:forcetypeassert // Just test code
-						t.Errorf("Filter should be %s got %s", tc.expectedFilter, data.(string))
-					}
-				}
-				if !sliceEq(odata.Select, tc.expectedSelect) {
-					t.Errorf("Select should be %v got %v", tc.expectedSelect, odata.Select)
-				}
-				if !orderByEq(odata.OrderBy, tc.expectedOrderBy) {
-					t.Errorf("OrderBy should be %v got %v", tc.expectedOrderBy, odata.OrderBy)
-				}
-				if odata.Top != tc.expectedTop {
-					t.Errorf("Top should be %d", tc.expectedTop)
-				}
-				if odata.Skip != tc.expectedSkip {
-					t.Errorf("Skip should be %d", tc.expectedSkip)
-				}
-				if odata.Count != tc.expectedCount {
-					t.Errorf("Count should be %t", tc.expectedCount)
-				}
-			}))
-			req := httptest.NewRequest(http.MethodGet, "/test?"+tc.input, nil)
-			middleware.ServeHTTP(httptest.NewRecorder(), req)
+			query, err := url.ParseQuery(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			queryOptions, err := middleware.Parse(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkQueryOptions(t, queryOptions, tc)
 		})
 	}
 }
 
+// serveFn runs a request through one of the adapters, it returns the response and the query options the handler saw.
+type serveFn func(t *testing.T, rawQuery string) (int, string, *odata.QueryOptions)
+
+// testAdapter checks that an adapter behaves exactly like the net/http middleware.
+func testAdapter(t *testing.T, serve serveFn) {
+	t.Helper()
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			code, _, queryOptions := serve(t, tc.input)
+			if code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", code)
+			}
+			checkQueryOptions(t, queryOptions, tc)
+		})
+	}
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		code, body, queryOptions := serve(t, "$top=ten")
+		if code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", code)
+		}
+		expected := `{"error":{"code":"InvalidValue","message":"strconv.ParseInt: parsing \"ten\": invalid syntax","target":"$top"}}`
+		if strings.TrimSpace(body) != expected {
+			t.Errorf("expected %s, got %s", expected, body)
+		}
+		if queryOptions != nil {
+			t.Error("handler should not be called")
+		}
+	})
+	t.Run("duplicate", func(t *testing.T) {
+		t.Parallel()
+		code, body, queryOptions := serve(t, "$top=1&$top=2")
+		if code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", code)
+		}
+		expected := `{"error":{"code":"DuplicateOption","message":"$top is given 2 times","target":"$top"}}`
+		if strings.TrimSpace(body) != expected {
+			t.Errorf("expected %s, got %s", expected, body)
+		}
+		if queryOptions != nil {
+			t.Error("handler should not be called")
+		}
+	})
+}
+
+//nolint:cyclop // This is just a test
+func checkQueryOptions(t *testing.T, queryOptions *odata.QueryOptions, tc testData) {
+	t.Helper()
+	if queryOptions == nil {
+		t.Fatal("Missing odata filter")
+	}
+	if queryOptions.Filter != nil && tc.expectedFilter == "" {
+		t.Error("Filter should be nil")
+	} else if queryOptions.Filter != nil {
+		data, err := queryOptions.Filter.GetDBQuery("mysql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		//nolint:forcetypeassert // Just test code
+		if data.(string) != tc.expectedFilter {
+			//nolint:forcetypeassert // Just test code
+			t.Errorf("Filter should be %s got %s", tc.expectedFilter, data.(string))
+		}
+	}
+	if !sliceEq(queryOptions.Select, tc.expectedSelect) {
+		t.Errorf("Select should be %v got %v", tc.expectedSelect, queryOptions.Select)
+	}
+	if !orderByEq(queryOptions.OrderBy, tc.expectedOrderBy) {
+		t.Errorf("OrderBy should be %v got %v", tc.expectedOrderBy, queryOptions.OrderBy)
+	}
+	if queryOptions.Top != tc.expectedTop {
+		t.Errorf("Top should be %d", tc.expectedTop)
+	}
+	if queryOptions.Skip != tc.expectedSkip {
+		t.Errorf("Skip should be %d", tc.expectedSkip)
+	}
+	if queryOptions.Count != tc.expectedCount {
+		t.Errorf("Count should be %t", tc.expectedCount)
+	}
+}
+
+func TestMiddlewareUnresolvedAlias(t *testing.T) {
+	t.Parallel()
+	for _, input := range []string{"$filter=Price%20lt%20@max", "$orderby=@sort", "$filter=Price%20lt%20@max&@max=Price"} {
+		middleware := middleware.NewOdataMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			t.Errorf("handler should not be called for %s", input)
+		}))
+		recorder := httptest.NewRecorder()
+		middleware.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test?"+input, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", input, recorder.Code)
+		}
+	}
+}
+
 func BenchmarkMiddlewareServeHTTP(b *testing.B) {
 	for _, test := range tests {
 		tc := test
@@ -243,3 +357,353 @@ func orderByEq(a, b *orderby.OrderBy) bool {
 	}
 	return true
 }
+
+func TestDefaultTop(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		limits      middleware.Limits
+		expectedTop int64
+	}{
+		{limits: middleware.Limits{}, expectedTop: -1},
+		{limits: middleware.Limits{DefaultTop: 20}, expectedTop: 20},
+		{limits: middleware.Limits{MaxTop: 50}, expectedTop: 50},
+		{limits: middleware.Limits{MaxTop: 50, DefaultTop: 20}, expectedTop: 20},
+		{limits: middleware.Limits{MaxTop: 50, DefaultTop: 80}, expectedTop: 50},
+	}
+	for _, tc := range tests {
+		m := middleware.NewOdataMiddleware(nil)
+		m.SetLimits(tc.limits)
+		queryOptions, err := m.Parse(url.Values{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if queryOptions.Top != tc.expectedTop {
+			t.Errorf("%+v: expected top %d, got %d", tc.limits, tc.expectedTop, queryOptions.Top)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+	limits := middleware.Limits{
+		MaxTop:             100,
+		DefaultTop:         20,
+		MaxFilterLength:    60,
+		MaxExpressionDepth: 3,
+		MaxFilterNodes:     12,
+		MaxInListSize:      3,
+		MaxOrderByItems:    2,
+		AllowedFunctions:   []string{"contains", "tolower"},
+	}
+	tests := []struct {
+		input       string
+		expectedErr string
+		expectedTop int64
+	}{
+		{input: "", expectedTop: 20},
+		{input: "$top=100", expectedTop: 100},
+		{input: "$top=101", expectedErr: "$top value of 101 exceeds the limit of 100"},
+		{input: "$top=-1", expectedErr: "$top: value must not be negative"},
+		{input: "$skip=-5", expectedErr: "$skip: value must not be negative"},
+		{input: "$filter=" + url.QueryEscape("Name eq '"+strings.Repeat("x", 60)+"'"), expectedErr: "$filter length of 70 exceeds the limit of 60"},
+		{input: "$filter=" + url.QueryEscape("((Price eq 1))"), expectedTop: 20},
+		{input: "$filter=" + url.QueryEscape("(((Price eq 1)))"), expectedTop: 20},
+		{input: "$filter=" + url.QueryEscape("((((Price eq 1))))"), expectedErr: "$filter depth of 4 exceeds the limit of 3"},
+		{input: "$filter=" + url.QueryEscape("Name in ('a', 'b', 'c')"), expectedTop: 20},
+		{input: "$filter=" + url.QueryEscape("Name in ('a', 'b', 'c', 'd')"), expectedErr: "$filter in list size of 4 exceeds the limit of 3"},
+		{input: "$filter=" + url.QueryEscape("Name in @names") + "&@names=" + url.QueryEscape(`["a","b,c","d","e"]`), expectedErr: "$filter in list size of 4 exceeds the limit of 3"},
+		{input: "$filter=" + url.QueryEscape("Name in @names") + "&@names=" + url.QueryEscape("["+strings.Repeat(`"a",`, 999)+`"a"]`), expectedErr: "$filter in list size of 1000 exceeds the limit of 3"},
+		{input: "$filter=" + url.QueryEscape("(ID eq 1 or ID eq 2) or ID eq 3"), expectedTop: 20},
+		{input: "$filter=" + url.QueryEscape("(ID eq 1 or ID eq 2) or (ID eq 3 or ID eq 4)"), expectedErr: "$filter nodes of 15 exceeds the limit of 12"},
+		{input: "$filter=" + url.QueryEscape("(ID in @a or ID in @a) or ID eq 1") + "&@a=" + url.QueryEscape("[1,2,3]"), expectedErr: "$filter nodes of 15 exceeds the limit of 12"},
+		{input: "$filter=" + url.QueryEscape("contains(tolower(Name),'milk')"), expectedTop: 20},
+		{input: "$filter=" + url.QueryEscape("matchesPattern(Name,'^M')"), expectedErr: "$filter function matchespattern is not allowed"},
+		{input: "$orderby=" + url.QueryEscape("Name,Price"), expectedTop: 20},
+		{input: "$orderby=" + url.QueryEscape("Name,Price desc,ID"), expectedErr: "$orderby items of 3 exceeds the limit of 2"},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			query, err := url.ParseQuery(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := middleware.NewOdataMiddleware(nil)
+			m.SetLimits(limits)
+			queryOptions, err := m.Parse(query)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Errorf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if queryOptions.Top != tc.expectedTop {
+				t.Errorf("expected top %d, got %d", tc.expectedTop, queryOptions.Top)
+			}
+		})
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input    string
+		snippets bool
+		expected string
+	}{
+		{
+			input: "$filter=" + url.QueryEscape("Name eq 'Milk"),
+			expected: `{"error":{"code":"SyntaxError","message":"no matching token \"'Milk\" at position 8, expected ' to close the string started at 8",` +
+				`"target":"$filter","innererror":{"start":8,"end":13}}}`,
+		},
+		{
+			input:    "$filter=" + url.QueryEscape("Name eq 'Milk"),
+			snippets: true,
+			expected: `{"error":{"code":"SyntaxError","message":"no matching token \"'Milk\" at position 8, expected ' to close the string started at 8",` +
+				`"target":"$filter","innererror":{"start":8,"end":13,"snippet":"Name eq 'Milk\n        ^^^^^"}}}`,
+		},
+		{
+			input:    "$filter=" + url.QueryEscape("Name eq @name"),
+			expected: `{"error":{"code":"UnresolvedAlias","message":"unresolved parameter alias @name","target":"$filter","innererror":{"start":8,"end":13}}}`,
+		},
+		{
+			input:    "$filter=" + url.QueryEscape("Name eq @x") + "&@x=" + url.QueryEscape("'Milk"),
+			expected: `{"error":{"code":"SyntaxError","message":"alias @x is not a literal value","target":"$filter","innererror":{"start":8,"end":10}}}`,
+		},
+		{
+			input:    "$filter=" + url.QueryEscape("(Name eq 'Milk'"),
+			snippets: true,
+			expected: `{"error":{"code":"SyntaxError","message":"expected ')' to close '(' opened at 0, found end of filter at 15","target":"$filter",` +
+				`"innererror":{"start":15,"end":15,"snippet":"(Name eq 'Milk'\n               ^"}}}`,
+		},
+		{
+			input:    "$orderby=" + url.QueryEscape("@sort desc"),
+			expected: `{"error":{"code":"UnresolvedAlias","message":"Unresolved parameter alias: @sort","target":"$orderby"}}`,
+		},
+		{
+			input:    "$orderby=" + url.QueryEscape("Name sideways"),
+			expected: `{"error":{"code":"SyntaxError","message":"Invalid order direction: SIDEWAYS","target":"$orderby"}}`,
+		},
+		{
+			input:    "$top=-1",
+			expected: `{"error":{"code":"InvalidValue","message":"$top: value must not be negative","target":"$top"}}`,
+		},
+		{
+			input:    "$top=101",
+			expected: `{"error":{"code":"LimitExceeded","message":"$top value of 101 exceeds the limit of 100","target":"$top"}}`,
+		},
+		{
+			input:    "$filter=" + url.QueryEscape("Name eq 'a' and matchesPattern(Name,'^M')"),
+			snippets: true,
+			expected: `{"error":{"code":"UnsupportedFunction","message":"$filter function matchespattern is not allowed","target":"$filter",` +
+				`"innererror":{"start":16,"end":30,"snippet":"Name eq 'a' and matchesPattern(Name,'^M')\n                ^^^^^^^^^^^^^^"}}}`,
+		},
+		{
+			input: "$count=maybe&$top=ten",
+			expected: `{"error":{"code":"InvalidValue","message":"strconv.ParseBool: parsing \"maybe\": invalid syntax","target":"$count",` +
+				`"details":[{"code":"InvalidValue","message":"strconv.ParseBool: parsing \"maybe\": invalid syntax","target":"$count"},` +
+				`{"code":"InvalidValue","message":"strconv.ParseInt: parsing \"ten\": invalid syntax","target":"$top"}]}}`,
+		},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+			m := middleware.NewOdataMiddleware(nil)
+			m.SetLimits(middleware.Limits{MaxTop: 100, AllowedFunctions: []string{"contains"}})
+			if tc.snippets {
+				m.EnableErrorSnippets()
+			}
+			r := httptest.NewRequest(http.MethodGet, "/?"+tc.input, nil)
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d", w.Code)
+			}
+			if strings.TrimSpace(w.Body.String()) != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOptionNames(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input       string
+		lenient     bool
+		expectedTop int64
+		expectedErr string
+	}{
+		{input: "$top=5", expectedTop: 5},
+		{input: "top=5", expectedTop: -1},
+		{input: "$TOP=5", expectedTop: -1},
+		{input: "$top=5&top=6", expectedTop: 5},
+		{input: "$top=5&$top=6", expectedErr: "$top is given 2 times"},
+		{input: "$top=5&$top=", expectedErr: "$top is given 2 times"},
+		{input: "$top=5", lenient: true, expectedTop: 5},
+		{input: "top=5", lenient: true, expectedTop: 5},
+		{input: "$Top=5", lenient: true, expectedTop: 5},
+		{input: "TOP=5", lenient: true, expectedTop: 5},
+		{input: "$top=5&top=6", lenient: true, expectedErr: "$top is given 2 times"},
+		{input: "$top=5&$TOP=6&Top=7", lenient: true, expectedErr: "$top is given 3 times"},
+		{input: "$top=5&$skip=1&skip=2&$count=true&$count=false", lenient: true, expectedErr: "$count is given 2 times\n$skip is given 2 times"},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(fmt.Sprintf("%s lenient=%t", tc.input, tc.lenient), func(t *testing.T) {
+			t.Parallel()
+			query, err := url.ParseQuery(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := middleware.NewOdataMiddleware(nil)
+			if tc.lenient {
+				m.EnableLenientOptionNames()
+			}
+			queryOptions, err := m.Parse(query)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Errorf("expected error %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if queryOptions.Top != tc.expectedTop {
+				t.Errorf("expected top %d, got %d", tc.expectedTop, queryOptions.Top)
+			}
+		})
+	}
+}
+
+func TestQueryRequest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path           string
+		contentType    string
+		body           string
+		expectedFilter string
+		expectedTop    int64
+		expectedSkip   int64
+		expectedErr    string
+	}{
+		{
+			path:           "/products/$query",
+			contentType:    "text/plain",
+			body:           "$filter=" + url.QueryEscape("ID in (1,2,3)") + "&$top=5\n",
+			expectedFilter: "`ID` IN (1,2,3)",
+			expectedTop:    5,
+			expectedSkip:   -1,
+		},
+		{
+			path:           "/products/$query?$skip=10",
+			contentType:    "application/x-www-form-urlencoded",
+			body:           "$filter=" + url.QueryEscape("Name eq 'Milk'"),
+			expectedFilter: "`Name`='Milk'",
+			expectedTop:    -1,
+			expectedSkip:   10,
+		},
+		{
+			path:           "/products/$query",
+			contentType:    "application/json; charset=utf-8",
+			body:           `{"$filter":"ID in @ids","$top":5,"@ids":[1,2,3]}`,
+			expectedFilter: "`ID` IN (1,2,3)",
+			expectedTop:    5,
+			expectedSkip:   -1,
+		},
+		{
+			path:        "/products/$query?$top=1",
+			contentType: "text/plain",
+			body:        "$top=2",
+			expectedErr: `{"error":{"code":"DuplicateOption","message":"$top is given 2 times","target":"$top"}}`,
+		},
+		{
+			path:        "/products/$query",
+			contentType: "application/xml",
+			body:        "<top>2</top>",
+			expectedErr: `{"error":{"code":"UnsupportedMediaType","message":"unsupported $query content type \"application/xml\"","target":"$query"}}`,
+		},
+		{
+			path:        "/products/$query",
+			contentType: "application/json",
+			body:        `{"$top":`,
+			expectedErr: `{"error":{"code":"SyntaxError","message":"invalid JSON query: unexpected end of JSON input","target":"$query"}}`,
+		},
+		{
+			path:        "/products/$query",
+			contentType: "text/plain",
+			body:        "$filter=" + strings.Repeat("x", 100),
+			expectedErr: `{"error":{"code":"LimitExceeded","message":"$query body size of 101 exceeds the limit of 100","target":"$query"}}`,
+		},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.path+" "+tc.body, func(t *testing.T) {
+			t.Parallel()
+			var queryOptions *odata.QueryOptions
+			var request *http.Request
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				queryOptions = middleware.GetOdataFromContext(r.Context())
+				request = r
+			})
+			m := middleware.NewOdataMiddleware(handler)
+			m.SetLimits(middleware.Limits{MaxQueryBodySize: 100})
+			r := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+			if tc.expectedErr != "" {
+				if w.Code != http.StatusBadRequest || strings.TrimSpace(w.Body.String()) != tc.expectedErr {
+					t.Errorf("expected 400 %s, got %d %s", tc.expectedErr, w.Code, w.Body.String())
+				}
+				return
+			}
+			if queryOptions == nil {
+				t.Fatalf("handler not called, got %d %s", w.Code, w.Body.String())
+			}
+			if request.Method != http.MethodGet || request.URL.Path != "/products" {
+				t.Errorf("expected GET /products, got %s %s", request.Method, request.URL.Path)
+			}
+			checkQueryOptions(t, queryOptions, testData{
+				expectedFilter: tc.expectedFilter,
+				expectedTop:    tc.expectedTop,
+				expectedSkip:   tc.expectedSkip,
+			})
+		})
+	}
+}
+
+func TestQueryBodySize(t *testing.T) {
+	t.Parallel()
+	body := "$filter=" + url.QueryEscape("Name eq '"+strings.Repeat("x", middleware.DefaultMaxQueryBodySize)+"'")
+	tests := []struct {
+		name         string
+		limits       middleware.Limits
+		expectedCode int
+	}{
+		{name: "default", limits: middleware.Limits{}, expectedCode: http.StatusBadRequest},
+		{name: "unlimited", limits: middleware.Limits{MaxQueryBodySize: -1}, expectedCode: http.StatusOK},
+		{name: "larger", limits: middleware.Limits{MaxQueryBodySize: 2 * middleware.DefaultMaxQueryBodySize}, expectedCode: http.StatusOK},
+	}
+	for _, test := range tests {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			m := middleware.NewOdataMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			m.SetLimits(tc.limits)
+			r := httptest.NewRequest(http.MethodPost, "/products/$query", strings.NewReader(body))
+			r.Header.Set("Content-Type", "text/plain")
+			w := httptest.NewRecorder()
+			m.ServeHTTP(w, r)
+			if w.Code != tc.expectedCode {
+				t.Errorf("expected %d, got %d %s", tc.expectedCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// QuerySegment is the last path segment of an OData 4.01 POST request that has its query options in the body, i.e.
+// POST /products/$query.
+const QuerySegment = "/$query"
+
+// IsQueryRequest reports whether r has its query options in the body.
+func IsQueryRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, QuerySegment)
+}
+
+// RewriteQueryRequest turns a POST .../$query into the GET it stands for, so neither routing nor the handler need to
+// know the query options came from the body. The body's options are added to any in the URL, giving one twice is
+// reported by Parse like any other duplicate. The body can be text/plain (or a form), which is a URL query string,
+// or a JSON object of option names to values. Requests that aren't $query requests are returned as is.
+func (o *OdataMiddleware) RewriteQueryRequest(r *http.Request) (*http.Request, error) {
+	if !IsQueryRequest(r) {
+		return r, nil
+	}
+	query, err := o.queryFromBody(r.URL.Query(), r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		return nil, err
+	}
+	ret := r.Clone(r.Context())
+	ret.Method = http.MethodGet
+	ret.URL.Path = strings.TrimSuffix(ret.URL.Path, QuerySegment)
+	ret.URL.RawPath = ""
+	ret.URL.RawQuery = query.Encode()
+	ret.RequestURI = ret.URL.RequestURI()
+	ret.Body = http.NoBody
+	ret.ContentLength = 0
+	ret.Header.Del("Content-Type")
+	ret.Header.Del("Content-Length")
+	return ret, nil
+}
+
+// queryFromBody adds the query options in a $query request's body to the ones in its URL. It's shared by the adapters
+// for frameworks that don't use net/http requests.
+func (o *OdataMiddleware) queryFromBody(query url.Values, contentType string, body io.Reader) (url.Values, error) {
+	options, err := o.readQueryBody(contentType, body)
+	if err != nil {
+		return nil, &OptionError{Option: "$query", Err: err}
+	}
+	for key, values := range options {
+		query[key] = append(query[key], values...)
+	}
+	return query, nil
+}
+
+func (o *OdataMiddleware) readQueryBody(contentType string, r io.Reader) (url.Values, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, &UnsupportedContentTypeError{ContentType: contentType}
+	}
+	if mediaType != "text/plain" && mediaType != "application/x-www-form-urlencoded" && mediaType != "application/json" {
+		return nil, &UnsupportedContentTypeError{ContentType: mediaType}
+	}
+	limit := o.limits.queryBodySize()
+	reader := r
+	if limit > 0 {
+		// One more than the limit to tell a body that's exactly the limit from one that's over it
+		reader = io.LimitReader(r, int64(limit)+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(body) > limit {
+		return nil, &LimitExceededError{Option: "$query", Limit: "body size", Max: limit, Value: len(body)}
+	}
+	if mediaType == "application/json" {
+		return parseJSONQuery(body)
+	}
+	return url.ParseQuery(string(bytes.TrimSpace(body)))
+}
+
+// parseJSONQuery reads {"$filter":"Name eq 'Milk'","$top":10,"@ids":[1,2]}, strings are used as they are and
+// anything else as its JSON text, which is what an alias holding an array expects.
+func parseJSONQuery(body []byte) (url.Values, error) {
+	options := map[string]json.RawMessage{}
+	err := json.Unmarshal(body, &options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON query: %w", err)
+	}
+	ret := url.Values{}
+	for key, raw := range options {
+		var value string
+		if json.Unmarshal(raw, &value) != nil {
+			value = string(raw)
+		}
+		ret.Add(key, value)
+	}
+	return ret, nil
+}
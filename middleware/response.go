@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/pboyd04/godata/errcode"
+)
+
+// ErrorResponse is the OData JSON error format, {"error":{"code":...,"message":...,"target":...}}.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes the first error, Details has every error when a request has more than one.
+type ErrorBody struct {
+	Code       errcode.Code  `json:"code"`
+	Message    string        `json:"message"`
+	Target     string        `json:"target,omitempty"`
+	Details    []ErrorDetail `json:"details,omitempty"`
+	InnerError *InnerError   `json:"innererror,omitempty"`
+}
+
+// ErrorDetail is one of the errors in a request.
+type ErrorDetail struct {
+	Code    errcode.Code `json:"code"`
+	Message string       `json:"message"`
+	Target  string       `json:"target,omitempty"`
+}
+
+// InnerError locates the error in the target's value. Start and End are byte offsets, Snippet is only filled in when
+// EnableErrorSnippets has been called.
+type InnerError struct {
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// EnableErrorSnippets adds the offending value with the error marked by carets to error responses, which is handy
+// during development but shouldn't be sent to clients that aren't yours.
+func (o *OdataMiddleware) EnableErrorSnippets() {
+	o.errorSnippets = true
+}
+
+func (o *OdataMiddleware) DisableErrorSnippets() {
+	o.errorSnippets = false
+}
+
+// NewErrorResponse builds the response body for an error from Parse, or for an error from translating the query
+// options wrapped in an OptionError.
+func (o *OdataMiddleware) NewErrorResponse(err error) *ErrorResponse {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok && len(joined.Unwrap()) > 0 {
+		errs = joined.Unwrap()
+	}
+	ret := &ErrorResponse{Error: ErrorBody{Code: codeOf(errs[0]), Message: errs[0].Error(), Target: targetOf(errs[0])}}
+	var optionErr *OptionError
+	if span, ok := errcode.SpanOf(errs[0]); ok && errors.As(errs[0], &optionErr) {
+		ret.Error.InnerError = &InnerError{Start: span.Start, End: span.End}
+		if o.errorSnippets {
+			ret.Error.InnerError.Snippet = errcode.Snippet(optionErr.Input, span)
+		}
+	}
+	if len(errs) == 1 {
+		return ret
+	}
+	for _, detail := range errs {
+		ret.Error.Details = append(ret.Error.Details, ErrorDetail{Code: codeOf(detail), Message: detail.Error(), Target: targetOf(detail)})
+	}
+	return ret
+}
+
+// WriteError sends err as a 400 in the OData JSON error format.
+func (o *OdataMiddleware) WriteError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	//nolint:errchkjson // Only strings and ints
+	_ = json.NewEncoder(w).Encode(o.NewErrorResponse(err))
+}
+
+// codeOf adds codes for the errors from the standard library that don't have one.
+func codeOf(err error) errcode.Code {
+	code := errcode.Of(err)
+	if code != errcode.BadRequest {
+		return code
+	}
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) || errors.Is(err, ErrNegativeValue) {
+		return errcode.InvalidValue
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return errcode.SyntaxError
+	}
+	return code
+}
+
+func targetOf(err error) string {
+	var optionErr *OptionError
+	if errors.As(err, &optionErr) {
+		return optionErr.Option
+	}
+	return ""
+}
@@ -44,6 +44,22 @@ func (q *QueryOptions) AddOrderBy(orderByString string) error {
 	return nil
 }
 
+// ResolveAliases replaces the parameter aliases used in $filter and $orderby with their values. An alias without a
+// value is an error.
+func (q *QueryOptions) ResolveAliases(aliases map[string]string) error {
+	if q.Filter != nil {
+		f, err := q.Filter.ResolveAliases(aliases)
+		if err != nil {
+			return err
+		}
+		q.Filter = f
+	}
+	if q.OrderBy != nil {
+		return q.OrderBy.ResolveAliases(aliases)
+	}
+	return nil
+}
+
 func (q *QueryOptions) AddTop(top int64) {
 	q.Top = top
 }
@@ -1,6 +1,10 @@
 package orderby
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/pboyd04/godata/errcode"
+)
 
 type OrderDirection int
 
@@ -55,6 +59,21 @@ func (o *OrderBy) parseOrderItem(orderByItem string) (OrderItem, error) {
 	}
 }
 
+// ResolveAliases replaces any parameter aliases (@name) used in place of a property with the supplied property name.
+func (o *OrderBy) ResolveAliases(aliases map[string]string) error {
+	for i, item := range o.OrderItem {
+		if !strings.HasPrefix(item.Property, "@") {
+			continue
+		}
+		value, ok := aliases[item.Property]
+		if !ok {
+			return &UnresolvedAliasError{Name: item.Property}
+		}
+		o.OrderItem[i].Property = strings.TrimSpace(value)
+	}
+	return nil
+}
+
 type InvalidOrderDirectionError struct {
 	Direction string
 }
@@ -62,3 +81,19 @@ type InvalidOrderDirectionError struct {
 func (e *InvalidOrderDirectionError) Error() string {
 	return "Invalid order direction: " + e.Direction
 }
+
+type UnresolvedAliasError struct {
+	Name string
+}
+
+func (e *UnresolvedAliasError) Error() string {
+	return "Unresolved parameter alias: " + e.Name
+}
+
+func (e *InvalidOrderDirectionError) ErrorCode() errcode.Code {
+	return errcode.SyntaxError
+}
+
+func (e *UnresolvedAliasError) ErrorCode() errcode.Code {
+	return errcode.UnresolvedAlias
+}